@@ -0,0 +1,128 @@
+package logutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-dt/appinfo"
+)
+
+// Keys of the attributes returned by AppInfoAttrs().
+const (
+	AppNameKey     = "app"
+	AppVersionKey  = "app_version"
+	GoVersionKey   = "go_version"
+	VCSRevisionKey = "vcs_revision"
+	VCSTimeKey     = "vcs_time"
+	VCSModifiedKey = "vcs_modified"
+	HostKey        = "host"
+	PIDKey         = "pid"
+	RunIDKey       = "run_id"
+)
+
+// AppInfoHeaderMessage is the message of the header record logged in
+// AppInfoHeader mode.
+const AppInfoHeaderMessage = "Log started"
+
+// AppInfoMode selects how the attributes returned by AppInfoAttrs() are added
+// to a logger's output.
+type AppInfoMode int
+
+const (
+	// OmitAppInfo adds no application attributes.
+	OmitAppInfo AppInfoMode = iota
+	// AppInfoPerRecord adds the attributes to every record.
+	AppInfoPerRecord
+	// AppInfoHeader logs a single AppInfoHeaderMessage record carrying the
+	// attributes when the logger is created, or for file loggers, when the file
+	// is created.
+	AppInfoHeader
+)
+
+// processAttrs returns the attributes that are the same for every AppInfo:
+// build information, hostname, PID and run ID.
+var processAttrs = sync.OnceValue(func() (attrs []slog.Attr) {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		attrs = append(attrs, slog.String(GoVersionKey, bi.GoVersion))
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				attrs = append(attrs, slog.String(VCSRevisionKey, s.Value))
+			case "vcs.time":
+				attrs = append(attrs, slog.String(VCSTimeKey, s.Value))
+			case "vcs.modified":
+				attrs = append(attrs, slog.Bool(VCSModifiedKey, s.Value == "true"))
+			}
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, slog.String(HostKey, host))
+	}
+	return append(attrs,
+		slog.Int(PIDKey, os.Getpid()),
+		slog.String(RunIDKey, RunID()),
+	)
+})
+
+// RunID returns a random ID generated once per process, distinguishing the logs
+// of separate runs of the same binary on the same host even when PIDs repeat.
+var RunID = sync.OnceValue(func() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+})
+
+// AppInfoAttrs returns attributes identifying the running application: its
+// name and version from ai, the Go version and VCS details recorded in the
+// binary, the hostname, PID and RunID(). When ai is nil or lacks them, the name
+// is that of the executable and the version that of the main module, if known.
+func AppInfoAttrs(ai appinfo.AppInfo) (attrs []slog.Attr) {
+	var name, version string
+
+	if ai != nil {
+		name = ai.Name()
+		version = string(ai.Version())
+	}
+	if name == "" {
+		name = filepath.Base(os.Args[0])
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && version == "" && bi.Main.Version != "(devel)" {
+		version = bi.Main.Version
+	}
+	attrs = append(attrs, slog.String(AppNameKey, name))
+	if version != "" {
+		attrs = append(attrs, slog.String(AppVersionKey, version))
+	}
+	return append(attrs, processAttrs()...)
+}
+
+// WithAppInfo returns l with the attributes returned by AppInfoAttrs() added as
+// selected by mode. In AppInfoHeader mode the header record is logged to l
+// regardless of its level and l is returned unchanged.
+func WithAppInfo(l *slog.Logger, ai appinfo.AppInfo, mode AppInfoMode) *slog.Logger {
+	h := withAppInfo(l.Handler(), ai, mode)
+	if mode == AppInfoPerRecord {
+		l = slog.New(h)
+	}
+	return l
+}
+
+// withAppInfo is WithAppInfo() for handlers.
+func withAppInfo(h slog.Handler, ai appinfo.AppInfo, mode AppInfoMode) slog.Handler {
+	switch mode {
+	case AppInfoPerRecord:
+		h = h.WithAttrs(AppInfoAttrs(ai))
+	case AppInfoHeader:
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, AppInfoHeaderMessage, 0)
+		r.AddAttrs(AppInfoAttrs(ai)...)
+		_ = h.Handle(context.Background(), r)
+	}
+	return h
+}
@@ -0,0 +1,188 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBatchQueueFull = errors.New("batch queue full")
+	ErrExporterClosed = errors.New("exporter closed")
+)
+
+// batcherArgs configures a batcher.
+type batcherArgs[T any] struct {
+	// maxItems is the number of items a batch is cut at.
+	maxItems int
+	// maxBytes, when positive, is the total size a batch is cut before exceeding.
+	maxBytes int
+	// size returns the size of an item counted against maxBytes.
+	size func(T) int
+	// interval is how often a partial batch is cut and exported.
+	interval time.Duration
+	// maxQueued is the number of cut batches held while export is slow or
+	// failing; the oldest is dropped when it is exceeded.
+	maxQueued int
+	// export sends a batch. Calls are serialized.
+	export func(ctx context.Context, batch []T) error
+	// drop receives batches that failed to export or were dropped from the queue,
+	// e.g. to spill them to disk.
+	drop func(batch []T, err error)
+}
+
+// batcher accumulates items into batches cut by count, size or time, and
+// exports them from a background goroutine so logging never waits on I/O.
+type batcher[T any] struct {
+	args     batcherArgs[T]
+	mu       sync.Mutex
+	items    []T
+	bytes    int
+	queue    [][]T
+	exportMu sync.Mutex
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	cancel   context.CancelFunc
+	once     sync.Once
+	closed   bool
+}
+
+// newBatcher returns a batcher and starts its export goroutine; close() stops
+// it.
+func newBatcher[T any](args batcherArgs[T]) *batcher[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &batcher[T]{
+		args:   args,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go b.run(ctx)
+	return b
+}
+
+func (b *batcher[T]) run(ctx context.Context) {
+	defer close(b.done)
+	ticker := time.NewTicker(b.args.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			b.cutLocked()
+			b.mu.Unlock()
+		case <-b.wake:
+		}
+		_ = b.drain(ctx)
+	}
+}
+
+// add adds item to the current batch, cutting it when full. Once closed, item
+// is dropped immediately as nothing would export it.
+func (b *batcher[T]) add(item T) {
+	var dropped [][]T
+
+	size := 0
+	if b.args.size != nil {
+		size = b.args.size(item)
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		b.args.drop([]T{item}, ErrExporterClosed)
+		return
+	}
+	if b.args.maxBytes > 0 && len(b.items) > 0 && b.bytes+size > b.args.maxBytes {
+		dropped = append(dropped, b.cutLocked()...)
+	}
+	b.items = append(b.items, item)
+	b.bytes += size
+	if len(b.items) >= b.args.maxItems {
+		dropped = append(dropped, b.cutLocked()...)
+	}
+	b.mu.Unlock()
+
+	for _, batch := range dropped {
+		b.args.drop(batch, ErrBatchQueueFull)
+	}
+}
+
+// cutLocked moves the current batch to the queue and wakes the export
+// goroutine, returning any batches dropped to stay within maxQueued.
+func (b *batcher[T]) cutLocked() (dropped [][]T) {
+	if len(b.items) == 0 {
+		goto end
+	}
+	b.queue = append(b.queue, b.items)
+	b.items = nil
+	b.bytes = 0
+	if n := len(b.queue) - b.args.maxQueued; n > 0 {
+		dropped = b.queue[:n:n]
+		b.queue = b.queue[n:]
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+end:
+	return dropped
+}
+
+// drain exports queued batches until the queue is empty.
+func (b *batcher[T]) drain(ctx context.Context) (err error) {
+	b.exportMu.Lock()
+	defer b.exportMu.Unlock()
+	for {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			b.mu.Unlock()
+			break
+		}
+		batch := b.queue[0]
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+
+		exportErr := b.args.export(ctx, batch)
+		if exportErr != nil {
+			b.args.drop(batch, exportErr)
+			err = errors.Join(err, exportErr)
+		}
+	}
+	return err
+}
+
+// flush cuts the current batch and exports everything queued.
+func (b *batcher[T]) flush(ctx context.Context) error {
+	b.mu.Lock()
+	dropped := b.cutLocked()
+	b.mu.Unlock()
+	for _, batch := range dropped {
+		b.args.drop(batch, ErrBatchQueueFull)
+	}
+	return b.drain(ctx)
+}
+
+// close stops the export goroutine, waiting for an in-flight export until ctx
+// is done, and then flushes what remains.
+func (b *batcher[T]) close(ctx context.Context) (err error) {
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+		close(b.stop)
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			b.cancel()
+			<-b.done
+		}
+		b.cancel()
+		err = b.flush(ctx)
+	})
+	return err
+}
@@ -0,0 +1,119 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mikeschinkel/go-cliutil"
+)
+
+var _ slog.Handler = (*CLIWriterHandler)(nil)
+
+// VerbosityOptions is implemented by *cliutil.CLIOptions and provides the values
+// of the -q/--quiet and -v/--verbosity CLI flags.
+type VerbosityOptions interface {
+	Quiet() bool
+	Verbosity() cliutil.Verbosity
+}
+
+// LevelForVerbosity returns the log level matching the CLI quiet and verbosity
+// settings: warnings and errors only when quiet, otherwise info at the default
+// verbosity, debug at verbosity 2 and everything at verbosity 3.
+func LevelForVerbosity(quiet bool, verbosity cliutil.Verbosity) (level slog.Level) {
+	switch {
+	case quiet:
+		level = slog.LevelWarn
+	case verbosity >= cliutil.HighVerbosity:
+		level = slog.LevelDebug - 4
+	case verbosity >= cliutil.MediumVerbosity:
+		level = slog.LevelDebug
+	default:
+		level = slog.LevelInfo
+	}
+	return level
+}
+
+// SetLevelFromOptions sets the level shared by logutil's loggers to match the
+// CLI quiet and verbosity settings so -q and -v control logs and human output
+// consistently.
+func SetLevelFromOptions(opts VerbosityOptions) {
+	SetLevel(LevelForVerbosity(opts.Quiet(), opts.Verbosity()))
+}
+
+type CLIWriterHandlerArgs struct {
+	// Level is the minimum level logged. Defaults to the level shared by
+	// logutil's loggers; see SetLevelFromOptions().
+	Level slog.Leveler
+	// Color controls color output.
+	Color ColorMode
+	// TimeFormat is as for ConsoleHandlerArgs but defaults to "-", i.e. omitting
+	// timestamps from user-facing output.
+	TimeFormat string
+}
+
+// CLIWriterHandler is a slog.Handler that renders records as ConsoleHandler does
+// and writes them through a cliutil.Writer so they honor its quiet and verbosity
+// settings: errors and warnings go to Errorf(), info to Printf(), debug to
+// V2().Printf() and lower levels to V3().Printf().
+type CLIWriterHandler struct {
+	writer  cliutil.Writer
+	console *ConsoleHandler
+}
+
+// NewCLIWriterHandler returns a handler writing through w. args may be nil.
+func NewCLIWriterHandler(w cliutil.Writer, args *CLIWriterHandlerArgs) *CLIWriterHandler {
+	if args == nil {
+		args = &CLIWriterHandlerArgs{}
+	}
+	a := *args
+	if a.TimeFormat == "" {
+		a.TimeFormat = "-"
+	}
+	return &CLIWriterHandler{
+		writer: w,
+		console: NewConsoleHandler(w.ErrWriter(), &ConsoleHandlerArgs{
+			Level:        a.Level,
+			Color:        a.Color,
+			TimeFormat:   a.TimeFormat,
+			MessageWidth: -1,
+		}),
+	}
+}
+
+// CreateCLIWriterLogger creates a logger writing through w.
+func CreateCLIWriterLogger(w cliutil.Writer) *slog.Logger {
+	return slog.New(NewCLIWriterHandler(w, nil))
+}
+
+func (h *CLIWriterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.console.Enabled(ctx, level)
+}
+
+func (h *CLIWriterHandler) Handle(_ context.Context, r slog.Record) error {
+	line := h.console.format(r)
+	switch {
+	case r.Level >= slog.LevelWarn:
+		h.writer.Errorf("%s", line)
+	case r.Level >= slog.LevelInfo:
+		h.writer.Printf("%s", line)
+	case r.Level >= slog.LevelDebug:
+		h.writer.V2().Printf("%s", line)
+	default:
+		h.writer.V3().Printf("%s", line)
+	}
+	return nil
+}
+
+func (h *CLIWriterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &CLIWriterHandler{
+		writer:  h.writer,
+		console: h.console.WithAttrs(attrs).(*ConsoleHandler),
+	}
+}
+
+func (h *CLIWriterHandler) WithGroup(name string) slog.Handler {
+	return &CLIWriterHandler{
+		writer:  h.writer,
+		console: h.console.WithGroup(name).(*ConsoleHandler),
+	}
+}
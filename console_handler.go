@@ -0,0 +1,351 @@
+package logutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var _ slog.Handler = (*ConsoleHandler)(nil)
+
+// ColorMode controls whether ConsoleHandler emits ANSI color escapes.
+type ColorMode int
+
+const (
+	// AutoColor colors output only when writing to a terminal and NO_COLOR is not
+	// set, unless FORCE_COLOR is set.
+	AutoColor ColorMode = iota
+	AlwaysColor
+	NeverColor
+)
+
+const (
+	DefaultConsoleTimeFormat   = "15:04:05.000"
+	DefaultConsoleMessageWidth = 40
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiMagenta = "\x1b[35m"
+)
+
+type ConsoleHandlerArgs struct {
+	// Level is the minimum level logged. Defaults to the level shared by
+	// logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	Color ColorMode
+	// TimeFormat is the time.Format() layout of timestamps; defaults to
+	// DefaultConsoleTimeFormat. Use "-" to omit timestamps.
+	TimeFormat string
+	// MessageWidth is the width messages are padded to so attributes align;
+	// defaults to DefaultConsoleMessageWidth. Negative values disable padding.
+	MessageWidth int
+	// AddSource adds a source=file:line attribute identifying the caller.
+	AddSource bool
+	// ReplaceAttr is called as documented for slog.HandlerOptions, for the
+	// built-in time, level, msg and source attributes as well as for all others.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+}
+
+// ConsoleHandler is a slog.Handler producing compact, human-oriented output with
+// level-colored prefixes, aligned messages and dimmed attributes, e.g.:
+//
+//	12:04:05.123 INF starting server                        addr=:8080
+type ConsoleHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	args   ConsoleHandlerArgs
+	color  bool
+	attrs  []byte
+	groups []string
+}
+
+// NewConsoleHandler returns a ConsoleHandler writing to w. args may be nil.
+func NewConsoleHandler(w io.Writer, args *ConsoleHandlerArgs) *ConsoleHandler {
+	if args == nil {
+		args = &ConsoleHandlerArgs{}
+	}
+	a := *args
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	if a.TimeFormat == "" {
+		a.TimeFormat = DefaultConsoleTimeFormat
+	}
+	if a.MessageWidth == 0 {
+		a.MessageWidth = DefaultConsoleMessageWidth
+	}
+	return &ConsoleHandler{
+		w:     w,
+		mu:    &sync.Mutex{},
+		args:  a,
+		color: useColor(w, a.Color),
+	}
+}
+
+// CreateStderrConsoleLogger creates a logger writing colorized, human-oriented
+// output to stderr.
+func CreateStderrConsoleLogger() *slog.Logger {
+	return slog.New(NewConsoleHandler(os.Stderr, nil))
+}
+
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.args.Level.Level()
+}
+
+func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) (err error) {
+	line := h.format(r)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = io.WriteString(h.w, line)
+	return err
+}
+
+// format renders r as a single newline-terminated line.
+func (h *ConsoleHandler) format(r slog.Record) string {
+	var sb strings.Builder
+	var attrs []byte
+	var a slog.Attr
+
+	if h.args.TimeFormat != "-" && !r.Time.IsZero() {
+		a = h.replace(nil, slog.Time(slog.TimeKey, r.Time))
+		if !a.Equal(slog.Attr{}) {
+			h.paint(&sb, ansiDim, h.timeString(a.Value))
+			sb.WriteByte(' ')
+		}
+	}
+
+	a = h.replace(nil, slog.Any(slog.LevelKey, r.Level))
+	if !a.Equal(slog.Attr{}) {
+		h.paint(&sb, levelColor(r.Level), levelString(a.Value))
+		sb.WriteByte(' ')
+	}
+
+	a = h.replace(nil, slog.String(slog.MessageKey, r.Message))
+	msg := a.Value.String()
+	sb.WriteString(msg)
+
+	if h.args.AddSource && r.PC != 0 {
+		attrs = h.appendAttr(attrs, nil, slog.Any(slog.SourceKey, r.Source()))
+	}
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = h.appendAttr(attrs, h.groups, a)
+		return true
+	})
+	if len(attrs) > 0 {
+		if pad := h.args.MessageWidth - len([]rune(msg)); pad > 0 {
+			sb.WriteString(strings.Repeat(" ", pad))
+		}
+		h.paint(&sb, ansiDim, string(attrs))
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		h2.attrs = h.appendAttr(h2.attrs, h.groups, a)
+	}
+	return &h2
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// replace applies the ReplaceAttr function, if any, to a.
+func (h *ConsoleHandler) replace(groups []string, a slog.Attr) slog.Attr {
+	if h.args.ReplaceAttr == nil {
+		return a
+	}
+	return h.args.ReplaceAttr(groups, a)
+}
+
+func (h *ConsoleHandler) timeString(v slog.Value) string {
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(h.args.TimeFormat)
+	}
+	return consoleValue(v)
+}
+
+// appendAttr appends " key=value" for a, flattening groups into dotted keys.
+func (h *ConsoleHandler) appendAttr(buf []byte, groups []string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		a = h.replace(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		goto end
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			buf = h.appendAttr(buf, groups, ga)
+		}
+		goto end
+	}
+	buf = append(buf, ' ')
+	for _, g := range groups {
+		buf = append(buf, g...)
+		buf = append(buf, '.')
+	}
+	buf = append(buf, a.Key...)
+	buf = append(buf, '=')
+	buf = append(buf, consoleValue(a.Value)...)
+end:
+	return buf
+}
+
+// paint writes s to sb wrapped in the given color when color is enabled.
+func (h *ConsoleHandler) paint(sb *strings.Builder, color, s string) {
+	if !h.color {
+		sb.WriteString(s)
+		return
+	}
+	sb.WriteString(color)
+	sb.WriteString(s)
+	sb.WriteString(ansiReset)
+}
+
+// consoleValue formats v, quoting strings that would otherwise be ambiguous.
+func consoleValue(v slog.Value) (s string) {
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		s = v.Duration().String()
+	case slog.KindAny:
+		switch t := v.Any().(type) {
+		case *slog.Source:
+			s = fmt.Sprintf("%s:%d", t.File, t.Line)
+		case error:
+			s = t.Error()
+		default:
+			s = fmt.Sprint(t)
+		}
+	default:
+		s = v.String()
+	}
+	if needsQuoting(s) {
+		s = strconv.Quote(s)
+	}
+	return s
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// levelLabel returns a fixed-width label for level such as "INF" or "WRN+2".
+func levelLabel(level slog.Level) string {
+	var base slog.Level
+	var label string
+
+	switch {
+	case level < slog.LevelInfo:
+		base, label = slog.LevelDebug, "DBG"
+	case level < slog.LevelWarn:
+		base, label = slog.LevelInfo, "INF"
+	case level < slog.LevelError:
+		base, label = slog.LevelWarn, "WRN"
+	default:
+		base, label = slog.LevelError, "ERR"
+	}
+	if level != base {
+		label = fmt.Sprintf("%s%+d", label, level-base)
+	}
+	return label
+}
+
+// levelString returns the label for a level attribute value, which ReplaceAttr
+// may have changed from a slog.Level into something else.
+func levelString(v slog.Value) string {
+	if level, ok := v.Any().(slog.Level); ok {
+		return levelLabel(level)
+	}
+	return v.String()
+}
+
+func levelColor(level slog.Level) (color string) {
+	switch {
+	case level < slog.LevelInfo:
+		color = ansiMagenta
+	case level < slog.LevelWarn:
+		color = ansiGreen
+	case level < slog.LevelError:
+		color = ansiYellow
+	default:
+		color = ansiBold + ansiRed
+	}
+	return color
+}
+
+// useColor decides whether to color output written to w. FORCE_COLOR overrides
+// everything but an explicit mode, then NO_COLOR and TERM=dumb disable color, and
+// otherwise color is used only when w is a terminal.
+func useColor(w io.Writer, mode ColorMode) (color bool) {
+	switch mode {
+	case AlwaysColor:
+		color = true
+		goto end
+	case NeverColor:
+		goto end
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		color = force != "0" && force != "false"
+		goto end
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		goto end
+	}
+	color = isTerminal(w)
+end:
+	return color
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,151 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+)
+
+var _ slog.Handler = (*ContextHandler)(nil)
+
+type ctxAttrsKey struct{}
+
+// ContextWithAttrs returns a copy of ctx carrying attrs in addition to any
+// attributes already stored in ctx. A ContextHandler appends them to every record
+// logged with that context, e.g. via InfoContext() or ErrorContext().
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	var existing, merged []slog.Attr
+
+	if len(attrs) == 0 {
+		goto end
+	}
+	existing = AttrsFromContext(ctx)
+	merged = make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	ctx = context.WithValue(ctx, ctxAttrsKey{}, merged)
+end:
+	return ctx
+}
+
+// ContextWithArgs is like ContextWithAttrs but accepts the same alternating
+// key/value and slog.Attr arguments accepted by slog.Logger.Info() et al.
+func ContextWithArgs(ctx context.Context, args ...any) context.Context {
+	return ContextWithAttrs(ctx, argsToAttrs(args)...)
+}
+
+// AttrsFromContext returns the attributes stored in ctx by ContextWithAttrs().
+func AttrsFromContext(ctx context.Context) (attrs []slog.Attr) {
+	if ctx == nil {
+		goto end
+	}
+	attrs, _ = ctx.Value(ctxAttrsKey{}).([]slog.Attr)
+end:
+	return attrs
+}
+
+// ContextHandler is a slog.Handler middleware that adds the trace context and the
+// attributes stored in the context by ContextWithAttrs() to each record before
+// passing it on. These are added at the top level of the record even when the
+// handler was created with WithGroup() so they can be found and joined on.
+type ContextHandler struct {
+	handler   slog.Handler
+	chain     attrChain
+	extractor TraceExtractor
+}
+
+type ContextHandlerArgs struct {
+	// TraceExtractor extracts the trace context from the context passed to
+	// Handle(). Defaults to TraceContextFromContext.
+	TraceExtractor TraceExtractor
+}
+
+// NewContextHandler wraps h so that context-carried attributes and trace context
+// are logged. args may be nil.
+func NewContextHandler(h slog.Handler, args *ContextHandlerArgs) *ContextHandler {
+	if args == nil {
+		args = &ContextHandlerArgs{}
+	}
+	if args.TraceExtractor == nil {
+		args.TraceExtractor = TraceContextFromContext
+	}
+	return &ContextHandler{
+		handler:   h,
+		extractor: args.TraceExtractor,
+	}
+}
+
+// WithContext returns a logger whose handler appends context-carried attributes
+// and trace context to records logged via l's *Context methods.
+func WithContext(l *slog.Logger) *slog.Logger {
+	if _, ok := l.Handler().(*ContextHandler); ok {
+		return l
+	}
+	return slog.New(NewContextHandler(l.Handler(), nil))
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	var nr slog.Record
+
+	attrs := h.contextAttrs(ctx)
+	if len(attrs) == 0 && len(h.chain) == 0 {
+		goto end
+	}
+	nr = slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	nr.AddAttrs(h.chain.nest(recordAttrs(r))...)
+	r = nr
+end:
+	return h.handler.Handle(ctx, r)
+}
+
+// contextAttrs returns the top-level attributes derived from ctx.
+func (h *ContextHandler) contextAttrs(ctx context.Context) (attrs []slog.Attr) {
+	ctxAttrs := AttrsFromContext(ctx)
+	tc, ok := h.extractor(ctx)
+	if !ok {
+		attrs = ctxAttrs
+		goto end
+	}
+	attrs = make([]slog.Attr, 0, len(ctxAttrs)+2)
+	attrs = append(attrs, tc.Attrs()...)
+	attrs = append(attrs, ctxAttrs...)
+end:
+	return attrs
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	if len(h.chain) == 0 {
+		h2.handler = h.handler.WithAttrs(attrs)
+		goto end
+	}
+	h2.chain = h.chain.withAttrs(attrs)
+end:
+	return &h2
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.chain = h.chain.withGroup(name)
+	return &h2
+}
+
+// Unwrap returns the handler wrapped by h.
+func (h *ContextHandler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+// argsToAttrs converts slog-style key/value arguments into attributes using the
+// same rules slog.Logger applies.
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	return recordAttrs(r)
+}
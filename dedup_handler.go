@@ -0,0 +1,254 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ slog.Handler = (*DedupHandler)(nil)
+	_ flusher      = (*DedupHandler)(nil)
+	_ io.Closer    = (*DedupHandler)(nil)
+)
+
+// DefaultDedupWindow is the default window of DedupHandler.
+const DefaultDedupWindow = 30 * time.Second
+
+type DedupHandlerArgs struct {
+	// Window is how long after a record identical consecutive records are
+	// collapsed into it. Once it elapses the repeat count is reported and the
+	// next identical record is logged. Defaults to DefaultDedupWindow.
+	Window time.Duration
+	// Now returns the current time and is used to measure the window, which the
+	// timer emitting the repeat record then waits on in real time; defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// DedupHandler is a slog.Handler middleware that collapses consecutive identical
+// records, i.e. with the same level, message and attributes, into the first one
+// followed later by a "message repeated N times" record, as syslog does. The
+// repeat record is emitted when a different record arrives, when the window
+// elapses, or when Flush() is called. Call Close() to stop the window's timer
+// and emit any pending repeat record.
+type DedupHandler struct {
+	handler slog.Handler
+	prefix  string
+	state   *dedupState
+}
+
+type dedupState struct {
+	mu      sync.Mutex
+	args    DedupHandlerArgs
+	key     string
+	level   slog.Level
+	msg     string
+	handler slog.Handler
+	since   time.Time
+	repeats int
+	// gen identifies the current record so a timer armed for an earlier one
+	// does nothing.
+	gen   uint64
+	timer *time.Timer
+}
+
+// NewDedupHandler wraps h to collapse repeated records. args may be nil.
+func NewDedupHandler(h slog.Handler, args *DedupHandlerArgs) *DedupHandler {
+	if args == nil {
+		args = &DedupHandlerArgs{}
+	}
+	a := *args
+	if a.Window <= 0 {
+		a.Window = DefaultDedupWindow
+	}
+	if a.Now == nil {
+		a.Now = time.Now
+	}
+	return &DedupHandler{
+		handler: h,
+		state:   &dedupState{args: a},
+	}
+}
+
+func (h *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *DedupHandler) Handle(ctx context.Context, r slog.Record) (err error) {
+	var pending *slog.Record
+	var pendingHandler slog.Handler
+
+	s := h.state
+	key := h.key(r)
+
+	s.mu.Lock()
+	now := s.args.Now()
+	if key == s.key && now.Sub(s.since) < s.args.Window {
+		s.repeats++
+		if s.repeats == 1 {
+			s.armLocked(s.args.Window - now.Sub(s.since))
+		}
+		s.mu.Unlock()
+		goto end
+	}
+	pending, pendingHandler = s.takeRepeats(now)
+	s.gen++
+	s.key = key
+	s.level = r.Level
+	s.msg = r.Message
+	s.handler = h.handler
+	s.since = now
+	s.mu.Unlock()
+
+	if pending != nil {
+		err = pendingHandler.Handle(ctx, *pending)
+	}
+	err = errors.Join(err, h.handler.Handle(ctx, r))
+end:
+	return err
+}
+
+// Flush emits the repeat record for the last record, if it has been repeated.
+func (h *DedupHandler) Flush(ctx context.Context) (err error) {
+	s := h.state
+	s.mu.Lock()
+	pending, handler := s.takeRepeats(s.args.Now())
+	s.mu.Unlock()
+	if pending != nil {
+		err = handler.Handle(ctx, *pending)
+	}
+	return err
+}
+
+// Close stops the window's timer and emits any pending repeat record.
+func (h *DedupHandler) Close() error {
+	s := h.state
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.gen++
+	s.mu.Unlock()
+	return h.Flush(context.Background())
+}
+
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var sb strings.Builder
+	sb.WriteString(h.prefix)
+	appendKeyAttrs(&sb, attrs)
+	return &DedupHandler{
+		handler: h.handler.WithAttrs(attrs),
+		prefix:  sb.String(),
+		state:   h.state,
+	}
+}
+
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &DedupHandler{
+		handler: h.handler.WithGroup(name),
+		prefix:  h.prefix + name + "{",
+		state:   h.state,
+	}
+}
+
+// Unwrap returns the handler wrapped by h.
+func (h *DedupHandler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+// key returns a string identifying r's level, message and attributes, including
+// those added to h with WithAttrs() and WithGroup().
+func (h *DedupHandler) key(r slog.Record) string {
+	var sb strings.Builder
+	sb.WriteString(r.Level.String())
+	sb.WriteByte(0)
+	sb.WriteString(r.Message)
+	sb.WriteByte(0)
+	sb.WriteString(h.prefix)
+	sb.WriteByte(0)
+	appendKeyAttrs(&sb, recordAttrs(r))
+	return sb.String()
+}
+
+func appendKeyAttrs(sb *strings.Builder, attrs []slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		sb.WriteString(a.Key)
+		if a.Value.Kind() == slog.KindGroup {
+			sb.WriteByte('{')
+			appendKeyAttrs(sb, a.Value.Group())
+			sb.WriteByte('}')
+			continue
+		}
+		sb.WriteByte('=')
+		sb.WriteString(a.Value.Kind().String())
+		_, _ = fmt.Fprintf(sb, "%q", a.Value.String())
+		sb.WriteByte(' ')
+	}
+}
+
+// armLocked arranges for the repeat record of the current record to be emitted
+// after d, when its window ends. The caller must hold s.mu.
+func (s *dedupState) armLocked(d time.Duration) {
+	gen := s.gen
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(d, func() {
+		s.expire(gen)
+	})
+}
+
+// expire emits the repeat record of the record identified by gen if it is still
+// current, so the next identical record is logged rather than counted.
+func (s *dedupState) expire(gen uint64) {
+	s.mu.Lock()
+	if gen != s.gen {
+		s.mu.Unlock()
+		return
+	}
+	pending, handler := s.takeRepeats(s.args.Now())
+	s.gen++
+	s.key = ""
+	s.mu.Unlock()
+	if pending != nil {
+		_ = handler.Handle(context.Background(), *pending)
+	}
+}
+
+// repeatedMessage returns the message of a repeat record for n repeats.
+func repeatedMessage(n int) string {
+	if n == 1 {
+		return "message repeated 1 time"
+	}
+	return fmt.Sprintf("message repeated %d times", n)
+}
+
+// takeRepeats returns the repeat record for the last record, if repeated, and the
+// handler to emit it with, resetting the count. The caller must hold s.mu.
+func (s *dedupState) takeRepeats(now time.Time) (r *slog.Record, h slog.Handler) {
+	var rec slog.Record
+
+	if s.repeats == 0 {
+		goto end
+	}
+	rec = slog.NewRecord(now, s.level, repeatedMessage(s.repeats), 0)
+	rec.AddAttrs(
+		slog.String("repeated_msg", s.msg),
+		slog.Int("repeated", s.repeats),
+		slog.Time("since", s.since),
+	)
+	r, h = &rec, s.handler
+	s.repeats = 0
+end:
+	return r, h
+}
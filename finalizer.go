@@ -0,0 +1,129 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var (
+	ErrFinalizerTimeout = errors.New("finalizer did not complete before deadline")
+	ErrFinalizerSkipped = errors.New("finalizer skipped after deadline")
+)
+
+// FinalizerFunc releases resources acquired during initialization, e.g. flushing
+// and closing log files. It should return promptly once ctx is done.
+type FinalizerFunc func(ctx context.Context) error
+
+type finalizer struct {
+	name string
+	fn   FinalizerFunc
+}
+
+// Finalizer pairs f with an initializer registered with RegisterInitializer(). f
+// is queued for CallFinalizerFuncs() only if the initializer succeeds.
+func Finalizer(f FinalizerFunc) InitializerOption {
+	return func(i *initializer) {
+		i.finalizer = f
+	}
+}
+
+// RegisterFinalizerFunc queues f to be called by CallFinalizerFuncs(). Finalizers
+// are called in reverse order of being queued, so those registered by an
+// initializer run before those of the initializers it ran after.
+func RegisterFinalizerFunc(f FinalizerFunc) {
+	initializers.registerFinalizer(callerSite(2), f)
+}
+
+// CallFinalizerFuncs calls the queued finalizers in reverse initialization order,
+// returning their errors joined. Finalizers still running or not yet started when
+// ctx is done are abandoned and reported as errors. The queue is emptied so
+// finalizers are called at most once.
+func CallFinalizerFuncs(ctx context.Context) error {
+	return initializers.CallFinalizers(ctx)
+}
+
+// FinalizeOnSignal calls CallFinalizerFuncs() with the given timeout when the
+// process receives SIGINT or SIGTERM and then exits with status 128+signal, as
+// shells do. Call stop to stop listening for the signals.
+func FinalizeOnSignal(timeout time.Duration) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-sigs:
+		case <-done:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := CallFinalizerFuncs(ctx)
+		cancel()
+		l := logger.Load()
+		if err != nil && l != nil {
+			l.Error("Finalizers failed", "signal", sig.String(), "error", err)
+		}
+		code := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(s)
+		}
+		os.Exit(code)
+	}()
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// RegisterFinalizerFunc queues f to be called by r.CallFinalizers().
+func (r *InitializerRegistry) RegisterFinalizerFunc(f FinalizerFunc) {
+	r.registerFinalizer(callerSite(2), f)
+}
+
+func (r *InitializerRegistry) registerFinalizer(name string, f FinalizerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finalizers = append(r.finalizers, &finalizer{
+		name: name,
+		fn:   f,
+	})
+}
+
+// CallFinalizers calls the finalizers queued with r in reverse order.
+func (r *InitializerRegistry) CallFinalizers(ctx context.Context) error {
+	var errs []error
+
+	r.mu.Lock()
+	finalizers := r.finalizers
+	r.finalizers = nil
+	r.mu.Unlock()
+	for n := len(finalizers) - 1; n >= 0; n-- {
+		f := finalizers[n]
+		if ctx.Err() != nil {
+			errs = append(errs, dt.NewErr(ErrFinalizerSkipped, "finalizer", f.name, ctx.Err()))
+			continue
+		}
+		errs = append(errs, f.call(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// call calls f, abandoning it if ctx is done before it returns.
+func (f *finalizer) call(ctx context.Context) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- f.fn(ctx)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = dt.NewErr(ErrFinalizerTimeout, "finalizer", f.name, ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package logutil
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// handlerUnwrapper is implemented by middleware handlers that wrap another handler.
+type handlerUnwrapper interface {
+	Unwrap() slog.Handler
+}
+
+// handlersGetter is implemented by handlers that dispatch to multiple handlers.
+type handlersGetter interface {
+	Handlers() []slog.Handler
+}
+
+// findHandler walks h and the handlers it wraps or dispatches to, depth first,
+// looking for one that is a T.
+func findHandler[T any](h slog.Handler) (found T, ok bool) {
+	for h != nil {
+		found, ok = h.(T)
+		if ok {
+			goto end
+		}
+		switch t := h.(type) {
+		case handlerUnwrapper:
+			h = t.Unwrap()
+		case handlersGetter:
+			for _, sub := range t.Handlers() {
+				found, ok = findHandler[T](sub)
+				if ok {
+					goto end
+				}
+			}
+			goto end
+		default:
+			goto end
+		}
+	}
+end:
+	return found, ok
+}
+
+// groupOrAttrs holds either a group name or attributes passed to WithGroup() or
+// WithAttrs().
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// attrChain records WithGroup() and WithAttrs() calls so middleware can defer
+// applying them until Handle(), allowing it to add top-level attributes of its
+// own while preserving the nesting of everything else.
+type attrChain []groupOrAttrs
+
+func (c attrChain) withAttrs(attrs []slog.Attr) attrChain {
+	return append(c[:len(c):len(c)], groupOrAttrs{attrs: attrs})
+}
+
+func (c attrChain) withGroup(name string) attrChain {
+	return append(c[:len(c):len(c)], groupOrAttrs{group: name})
+}
+
+// nest wraps attrs in the groups recorded by c, prepending any attributes
+// recorded at each level. Empty groups are dropped as slog handlers do.
+func (c attrChain) nest(attrs []slog.Attr) []slog.Attr {
+	for i := len(c) - 1; i >= 0; i-- {
+		goa := c[i]
+		if goa.group == "" {
+			attrs = append(goa.attrs[:len(goa.attrs):len(goa.attrs)], attrs...)
+			continue
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		attrs = []slog.Attr{{Key: goa.group, Value: slog.GroupValue(attrs...)}}
+	}
+	return attrs
+}
+
+// recordAttrs returns the attributes of r as a slice.
+func recordAttrs(r slog.Record) (attrs []slog.Attr) {
+	attrs = make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// collectHandlers returns h and every handler it wraps or dispatches to that is
+// a T.
+func collectHandlers[T any](h slog.Handler) (found []T) {
+	for h != nil {
+		if t, ok := h.(T); ok {
+			found = append(found, t)
+		}
+		switch t := h.(type) {
+		case handlerUnwrapper:
+			h = t.Unwrap()
+		case handlersGetter:
+			for _, sub := range t.Handlers() {
+				found = append(found, collectHandlers[T](sub)...)
+			}
+			h = nil
+		default:
+			h = nil
+		}
+	}
+	return found
+}
+
+// flatAttr is an attribute flattened to a dotted key and formatted value, for
+// handlers writing to formats without nesting.
+type flatAttr struct {
+	key   string
+	value string
+}
+
+// appendFlatAttrs appends a to attrs, flattening groups into dotted keys.
+func appendFlatAttrs(attrs []flatAttr, groups []string, a slog.Attr) []flatAttr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		goto end
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendFlatAttrs(attrs, groups, ga)
+		}
+		goto end
+	}
+	attrs = append(attrs, flatAttr{
+		key:   strings.Join(append(groups[:len(groups):len(groups)], a.Key), "."),
+		value: plainValue(a.Value),
+	})
+end:
+	return attrs
+}
+
+// plainValue formats v without the quoting consoleValue() applies.
+func plainValue(v slog.Value) (s string) {
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch t := v.Any().(type) {
+		case error:
+			s = t.Error()
+		default:
+			s = fmt.Sprint(t)
+		}
+	default:
+		s = v.String()
+	}
+	return s
+}
@@ -0,0 +1,381 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var ErrMissingEndpoint = errors.New("missing endpoint URL")
+
+var _ slog.Handler = (*HTTPBatchHandler)(nil)
+
+var (
+	_ flusher   = (*HTTPBatchHandler)(nil)
+	_ io.Closer = (*HTTPBatchHandler)(nil)
+)
+
+// HTTPBodyFormat selects how HTTPBatchHandler encodes a batch of JSON records.
+type HTTPBodyFormat int
+
+const (
+	// NDJSONBody sends one JSON record per line as application/x-ndjson.
+	NDJSONBody HTTPBodyFormat = iota
+	// JSONArrayBody sends a JSON array of records as application/json.
+	JSONArrayBody
+)
+
+const (
+	DefaultHTTPBatchSize     = 100
+	DefaultHTTPBatchBytes    = 1 << 20
+	DefaultHTTPBatchInterval = 5 * time.Second
+)
+
+type HTTPBatchHandlerArgs struct {
+	// URL is the endpoint batches are POSTed to. Required.
+	URL    string
+	Format HTTPBodyFormat
+	// Headers are added to each request, e.g. for authentication.
+	Headers map[string]string
+	// DisableGzip sends requests uncompressed; by default they are gzipped.
+	DisableGzip bool
+	// Client defaults to an http.Client with a timeout of DefaultExportTimeout.
+	Client *http.Client
+	// Level is the minimum level sent. Defaults to the level shared by logutil's
+	// loggers; see SetLevel().
+	Level       slog.Leveler
+	AddSource   bool
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+	// BatchSize is the maximum number of records per request; defaults to
+	// DefaultHTTPBatchSize.
+	BatchSize int
+	// BatchBytes is the maximum uncompressed size of a request body; defaults to
+	// DefaultHTTPBatchBytes.
+	BatchBytes int
+	// BatchInterval is how often a partial batch is sent; defaults to
+	// DefaultHTTPBatchInterval.
+	BatchInterval time.Duration
+	// MaxQueuedBatches is the number of batches held while the endpoint is slow
+	// or unreachable before the oldest is spilled or dropped; defaults to
+	// DefaultMaxQueuedBatches.
+	MaxQueuedBatches int
+	Backoff          BackoffArgs
+	// SpillFile, when set, receives records that could not be sent after
+	// retrying, as JSON lines. They are replayed once the endpoint accepts a
+	// batch again, including by later processes.
+	SpillFile dt.Filepath
+	// OnError, when set, is called with errors sending in the background or
+	// writing SpillFile.
+	OnError func(err error)
+	// RegisterFinalizer has CreateHTTPBatchLogger() queue the handler's
+	// Shutdown() with RegisterFinalizerFunc() so CallFinalizerFuncs() sends the
+	// remaining records. Otherwise the caller closes the handler.
+	RegisterFinalizer bool
+}
+
+// HTTPBatchHandler is a slog.Handler shipping records as JSON to an HTTP
+// endpoint, such as a self-hosted log aggregator. Records are batched by count,
+// size and time, and sent in the background with retries and jittered backoff,
+// so call Close(), or CallFinalizerFuncs() when registered by
+// CreateHTTPBatchLogger(), to send what remains before exiting.
+type HTTPBatchHandler struct {
+	*slog.JSONHandler
+	sink *httpBatchSink
+}
+
+// httpBatchSink is shared by an HTTPBatchHandler and the handlers derived from
+// it by WithAttrs() and WithGroup(). Its Write() receives one JSON record per
+// call from the slog.JSONHandler.
+type httpBatchSink struct {
+	args    HTTPBatchHandlerArgs
+	http    *httpExporter
+	batcher *batcher[[]byte]
+	spillMu sync.Mutex
+}
+
+// NewHTTPBatchHandler returns an HTTPBatchHandler configured by args.
+func NewHTTPBatchHandler(args *HTTPBatchHandlerArgs) (h *HTTPBatchHandler, err error) {
+	var s *httpBatchSink
+
+	if args == nil || args.URL == "" {
+		err = ErrMissingEndpoint
+		goto end
+	}
+	s = &httpBatchSink{args: *args}
+	s.setDefaults()
+	s.http = &httpExporter{
+		client:      s.args.Client,
+		url:         s.args.URL,
+		contentType: "application/x-ndjson",
+		headers:     s.args.Headers,
+		gzip:        !s.args.DisableGzip,
+	}
+	if s.args.Format == JSONArrayBody {
+		s.http.contentType = "application/json"
+	}
+	s.batcher = newBatcher(batcherArgs[[]byte]{
+		maxItems:  s.args.BatchSize,
+		maxBytes:  s.args.BatchBytes,
+		size:      func(line []byte) int { return len(line) },
+		interval:  s.args.BatchInterval,
+		maxQueued: s.args.MaxQueuedBatches,
+		export:    s.export,
+		drop:      s.spill,
+	})
+	h = &HTTPBatchHandler{
+		JSONHandler: slog.NewJSONHandler(s, &slog.HandlerOptions{
+			Level:       s.args.Level,
+			AddSource:   s.args.AddSource,
+			ReplaceAttr: s.args.ReplaceAttr,
+		}),
+		sink: s,
+	}
+end:
+	return h, err
+}
+
+func (s *httpBatchSink) setDefaults() {
+	a := &s.args
+	if a.Client == nil {
+		a.Client = &http.Client{Timeout: DefaultExportTimeout}
+	}
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	if a.BatchSize <= 0 {
+		a.BatchSize = DefaultHTTPBatchSize
+	}
+	if a.BatchBytes <= 0 {
+		a.BatchBytes = DefaultHTTPBatchBytes
+	}
+	if a.BatchInterval <= 0 {
+		a.BatchInterval = DefaultHTTPBatchInterval
+	}
+	if a.MaxQueuedBatches <= 0 {
+		a.MaxQueuedBatches = DefaultMaxQueuedBatches
+	}
+	a.Backoff = a.Backoff.withDefaults()
+}
+
+// CreateHTTPBatchLogger creates a logger shipping records to an HTTP endpoint;
+// see HTTPBatchHandlerArgs.RegisterFinalizer for sending the remaining records.
+func CreateHTTPBatchLogger(args *HTTPBatchHandlerArgs) (logger *slog.Logger, err error) {
+	var h *HTTPBatchHandler
+
+	h, err = NewHTTPBatchHandler(args)
+	if err != nil {
+		goto end
+	}
+	if h.sink.args.RegisterFinalizer {
+		RegisterFinalizerFunc(h.Shutdown)
+	}
+	logger = slog.New(h)
+end:
+	return logger, err
+}
+
+func (h *HTTPBatchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &HTTPBatchHandler{
+		JSONHandler: h.JSONHandler.WithAttrs(attrs).(*slog.JSONHandler),
+		sink:        h.sink,
+	}
+}
+
+func (h *HTTPBatchHandler) WithGroup(name string) slog.Handler {
+	return &HTTPBatchHandler{
+		JSONHandler: h.JSONHandler.WithGroup(name).(*slog.JSONHandler),
+		sink:        h.sink,
+	}
+}
+
+// Flush sends the records batched so far.
+func (h *HTTPBatchHandler) Flush(ctx context.Context) error {
+	return h.sink.batcher.flush(ctx)
+}
+
+// Shutdown stops background sending and sends the records that remain, giving
+// up when ctx is done.
+func (h *HTTPBatchHandler) Shutdown(ctx context.Context) error {
+	return h.sink.batcher.close(ctx)
+}
+
+// Close calls Shutdown() allowing DefaultExportTimeout.
+func (h *HTTPBatchHandler) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultExportTimeout)
+	defer cancel()
+	return h.Shutdown(ctx)
+}
+
+// Write adds a newline-terminated JSON record written by slog.JSONHandler to the
+// current batch.
+func (s *httpBatchSink) Write(p []byte) (int, error) {
+	s.batcher.add(bytes.Clone(p))
+	return len(p), nil
+}
+
+// export sends batch, retrying as configured, and then replays any spilled
+// records now that the endpoint is reachable. Only an error sending batch is
+// returned, as batch is spilled on error; replay errors are passed to OnError.
+func (s *httpBatchSink) export(ctx context.Context, batch [][]byte) (err error) {
+	var replayErr error
+
+	err = s.send(ctx, batch)
+	if err != nil {
+		goto end
+	}
+	replayErr = s.replay(ctx)
+	if replayErr != nil && s.args.OnError != nil {
+		s.args.OnError(dt.NewErr(ErrExportFailed, "spill_file", s.args.SpillFile, replayErr))
+	}
+end:
+	if err != nil && s.args.OnError != nil {
+		s.args.OnError(err)
+	}
+	return err
+}
+
+// send posts lines as one request body, retrying as configured.
+func (s *httpBatchSink) send(ctx context.Context, lines [][]byte) error {
+	var body []byte
+
+	if s.args.Format == JSONArrayBody {
+		body = append(body, '[')
+		for i, line := range lines {
+			if i > 0 {
+				body = append(body, ',')
+			}
+			body = append(body, bytes.TrimSuffix(line, []byte{'\n'})...)
+		}
+		body = append(body, ']')
+	} else {
+		body = bytes.Join(lines, nil)
+	}
+	return retry(ctx, s.args.Backoff, func(ctx context.Context) error {
+		return s.http.post(ctx, body)
+	})
+}
+
+// spill appends lines, which failed to send or were dropped from the queue, to
+// SpillFile if set.
+func (s *httpBatchSink) spill(lines [][]byte, cause error) {
+	var err error
+
+	if s.args.SpillFile == "" {
+		if errors.Is(cause, ErrBatchQueueFull) || errors.Is(cause, ErrExporterClosed) {
+			// Send failures were reported by export().
+			err = cause
+		}
+		goto end
+	}
+	s.spillMu.Lock()
+	err = appendLines(s.args.SpillFile, lines)
+	s.spillMu.Unlock()
+end:
+	if err != nil && s.args.OnError != nil {
+		s.args.OnError(dt.NewErr(ErrExportFailed, "spill_file", s.args.SpillFile, "records", len(lines), errors.Join(cause, err)))
+	}
+}
+
+// replaying returns the file spilled records are moved to while being replayed.
+func (s *httpBatchSink) replaying() dt.Filepath {
+	return s.args.SpillFile + ".replay"
+}
+
+// replay sends the records in SpillFile in batches. The file is first renamed so
+// records spilled meanwhile are kept apart; should a batch fail, it and the
+// records after it are spilled again. Should that fail too, the renamed file is
+// kept, trimmed to the unsent records if possible, for the next replay.
+func (s *httpBatchSink) replay(ctx context.Context) (err error) {
+	var data []byte
+	var lines [][]byte
+	var start int
+	var size int
+	var spillErr error
+
+	if s.args.SpillFile == "" {
+		goto end
+	}
+	data, err = s.claimSpill()
+	if err != nil || len(data) == 0 {
+		goto end
+	}
+	lines = bytes.SplitAfter(data, []byte{'\n'})
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		full := i-start >= s.args.BatchSize || size+len(line) > s.args.BatchBytes
+		if full && i > start {
+			err = s.send(ctx, lines[start:i])
+			if err != nil {
+				break
+			}
+			start, size = i, 0
+		}
+		size += len(line)
+	}
+	if err == nil {
+		err = s.send(ctx, lines[start:])
+	}
+	s.spillMu.Lock()
+	defer s.spillMu.Unlock()
+	if err != nil {
+		spillErr = appendLines(s.args.SpillFile, lines[start:])
+	}
+	if spillErr != nil {
+		err = errors.Join(err, spillErr, writeFileAtomic(s.replaying(), bytes.Join(lines[start:], nil)))
+		goto end
+	}
+	err = errors.Join(err, s.replaying().Remove())
+end:
+	return err
+}
+
+// claimSpill moves SpillFile aside for replay, unless a previous replay was
+// interrupted, and returns the records to replay.
+func (s *httpBatchSink) claimSpill() (data []byte, err error) {
+	var exists bool
+
+	s.spillMu.Lock()
+	defer s.spillMu.Unlock()
+	exists, err = s.replaying().Exists()
+	if err != nil || exists {
+		goto end
+	}
+	exists, err = s.args.SpillFile.Exists()
+	if err != nil || !exists {
+		goto end
+	}
+	err = os.Rename(string(s.args.SpillFile), string(s.replaying()))
+end:
+	if err == nil && exists {
+		data, err = s.replaying().ReadFile()
+	}
+	return data, err
+}
+
+// appendLines appends lines to file, creating it and its directory as needed.
+func appendLines(file dt.Filepath, lines [][]byte) (err error) {
+	var f *os.File
+
+	err = ensureDir(file.Dir())
+	if err != nil {
+		goto end
+	}
+	f, err = file.OpenFile(os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		goto end
+	}
+	_, err = f.Write(bytes.Join(lines, nil))
+	err = errors.Join(err, f.Close())
+end:
+	return err
+}
@@ -0,0 +1,175 @@
+package logutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var ErrExportFailed = errors.New("log export failed")
+
+const (
+	DefaultBackoffAttempts = 5
+	DefaultBackoffInitial  = 500 * time.Millisecond
+	DefaultBackoffMax      = 30 * time.Second
+	DefaultExportTimeout   = 10 * time.Second
+)
+
+// BackoffArgs configures how failed exports are retried. Delays double from
+// Initial up to Max, with jitter so that many processes do not retry in step,
+// and honor Retry-After headers up to Max.
+type BackoffArgs struct {
+	// MaxAttempts is the number of attempts made, including the first; defaults
+	// to DefaultBackoffAttempts. Use 1 to disable retries.
+	MaxAttempts int
+	// Initial defaults to DefaultBackoffInitial.
+	Initial time.Duration
+	// Max defaults to DefaultBackoffMax.
+	Max time.Duration
+}
+
+func (a BackoffArgs) withDefaults() BackoffArgs {
+	if a.MaxAttempts <= 0 {
+		a.MaxAttempts = DefaultBackoffAttempts
+	}
+	if a.Initial <= 0 {
+		a.Initial = DefaultBackoffInitial
+	}
+	if a.Max <= 0 {
+		a.Max = DefaultBackoffMax
+	}
+	return a
+}
+
+// delay returns the delay before the retry following the given attempt, chosen
+// at random between half and all of the exponential backoff.
+func (a BackoffArgs) delay(attempt int) time.Duration {
+	d := a.Initial << (attempt - 1)
+	if d > a.Max || d <= 0 {
+		d = a.Max
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// retryableError marks an export error as worth retrying, optionally after a
+// delay requested by the server.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// retry calls fn until it succeeds, returns an error not marked retryable,
+// MaxAttempts is reached or ctx is done.
+func retry(ctx context.Context, args BackoffArgs, fn func(ctx context.Context) error) (err error) {
+	var re *retryableError
+
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || !errors.As(err, &re) || attempt >= args.MaxAttempts {
+			goto end
+		}
+		d := args.delay(attempt)
+		if re.after > 0 {
+			d = min(re.after, args.Max)
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = errors.Join(err, ctx.Err())
+			goto end
+		case <-timer.C:
+		}
+	}
+end:
+	return err
+}
+
+// httpExporter POSTs request bodies to a log ingestion endpoint.
+type httpExporter struct {
+	client      *http.Client
+	url         string
+	contentType string
+	headers     map[string]string
+	gzip        bool
+}
+
+// post sends body once. Network errors and 429, 502, 503 and 504 responses are
+// returned as retryable.
+func (e *httpExporter) post(ctx context.Context, body []byte) (err error) {
+	var req *http.Request
+	var resp *http.Response
+	var buf bytes.Buffer
+
+	if e.gzip {
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		body = buf.Bytes()
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		err = dt.NewErr(ErrExportFailed, "url", e.url, err)
+		goto end
+	}
+	req.Header.Set("Content-Type", e.contentType)
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err = e.client.Do(req)
+	if err != nil {
+		err = dt.NewErr(ErrExportFailed, "url", e.url, err)
+		if ctx.Err() == nil {
+			err = &retryableError{err: err}
+		}
+		goto end
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		goto end
+	}
+	err = dt.NewErr(ErrExportFailed, "url", e.url, "status", resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		err = &retryableError{
+			err:   err,
+			after: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+end:
+	return err
+}
+
+// retryAfter parses a Retry-After header given in seconds, returning 0 when it
+// is missing or given as a date.
+func retryAfter(header string) (d time.Duration) {
+	secs, err := strconv.Atoi(header)
+	if err == nil && secs > 0 {
+		d = time.Duration(secs) * time.Second
+	}
+	return d
+}
@@ -1,55 +1,491 @@
 package logutil
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mikeschinkel/go-cliutil"
+	"github.com/mikeschinkel/go-dt"
 	"github.com/mikeschinkel/go-dt/appinfo"
 )
 
+var (
+	ErrDuplicateInitializer = errors.New("duplicate initializer name")
+	ErrUnknownInitializer   = errors.New("unknown initializer dependency")
+	ErrInitializerCycle     = errors.New("initializer dependency cycle")
+	ErrInitializerPanicked  = errors.New("initializer panicked")
+	ErrInitializerTimeout   = errors.New("initializer did not complete before deadline")
+	ErrInitializerSkipped   = errors.New("initializer skipped after context was done")
+)
+
+// PackageKey is the attribute key identifying the package a logger was scoped to.
+const PackageKey = "pkg"
+
 type InitializerArgs struct {
 	appinfo.AppInfo
+	// AppInfoMode selects whether Logger is extended with attributes identifying
+	// the application before initializers are called; see AppInfoAttrs().
+	AppInfoMode AppInfoMode
+	// Logger is the application's logger. Each initializer receives it scoped to
+	// the package that registered the initializer with a PackageKey attribute.
 	Logger *slog.Logger
 	Writer cliutil.Writer
+	// Package is the import path of the package that registered the initializer
+	// being called.
+	Package string
+	// Context is set for each initializer called and is done once the context
+	// passed to CallInitializerFuncsContext() is done or the initializer's
+	// Timeout elapses. Initializers that block should return when it is done.
+	Context context.Context
+	// root is the unscoped application logger.
+	root *slog.Logger
+}
+
+// scoped returns a copy of args for an initializer registered by pkg.
+func (args InitializerArgs) scoped(pkg string) InitializerArgs {
+	if args.root == nil {
+		args.root = args.Logger
+	}
+	args.Package = pkg
+	if args.root != nil && pkg != "" {
+		args.Logger = args.root.With(PackageKey, pkg)
+	}
+	return args
 }
 
 type InitializerFunc func(InitializerArgs) error
 
-var initializerFuncs []InitializerFunc
+// InitializerOption configures an initializer registered with RegisterInitializer().
+type InitializerOption func(*initializer)
+
+// After declares that an initializer must run after the initializers with the
+// given names.
+func After(names ...string) InitializerOption {
+	return func(i *initializer) {
+		i.after = append(i.after, names...)
+	}
+}
+
+// Timeout limits how long CallInitializerFuncsContext() waits for an initializer,
+// after which InitializerArgs.Context is done. An initializer that ignores it
+// keeps running in the background, alongside the initializers called after it.
+func Timeout(d time.Duration) InitializerOption {
+	return func(i *initializer) {
+		i.timeout = d
+	}
+}
+
+// InitializerInfo describes a registered initializer.
+type InitializerInfo struct {
+	// Name is the name passed to RegisterInitializer(), or empty for initializers
+	// registered with RegisterInitializerFunc().
+	Name string
+	// After lists the names of the initializers it must run after.
+	After []string
+	// Site is the file:line the initializer was registered from.
+	Site string
+}
+
+type initializer struct {
+	name      string
+	fn        InitializerFunc
+	after     []string
+	site      string
+	pkg       string
+	finalizer FinalizerFunc
+	timeout   time.Duration
+}
+
+// String returns the name of the initializer, or its registration site if unnamed.
+func (i *initializer) String() string {
+	if i.name != "" {
+		return i.name
+	}
+	return i.site
+}
+
+// InitializerRegistry holds initializers to be called in dependency order. Most
+// code uses the package-level registry via RegisterInitializer() and
+// CallInitializerFuncs().
+type InitializerRegistry struct {
+	mu           sync.Mutex
+	initializers []*initializer
+	finalizers   []*finalizer
+}
+
+func NewInitializerRegistry() *InitializerRegistry {
+	return &InitializerRegistry{}
+}
+
+// initializers is the package-level registry.
+var initializers = NewInitializerRegistry()
 
+// RegisterInitializerFunc registers an unnamed initializer. Unnamed initializers
+// cannot be depended on and run in registration order relative to each other.
 func RegisterInitializerFunc(f InitializerFunc) {
-	initializerFuncs = append(initializerFuncs, f)
+	initializers.register("", f, nil)
+}
+
+// RegisterInitializer registers an initializer named name, typically the name of
+// the registering package, to be called by CallInitializerFuncs() after those it
+// was declared to run After().
+func RegisterInitializer(name string, f InitializerFunc, opts ...InitializerOption) {
+	initializers.register(name, f, opts)
+}
+
+// Initializers returns the registered initializers in the order
+// CallInitializerFuncs() will call them.
+func Initializers() ([]InitializerInfo, error) {
+	return initializers.Initializers()
+}
+
+// CallInitializerFuncs calls the registered initializers, each after those it
+// depends on and otherwise in registration order, returning their errors joined.
+func CallInitializerFuncs(args InitializerArgs) error {
+	return initializers.CallContext(context.Background(), args)
+}
+
+// CallInitializerFuncsContext is like CallInitializerFuncs() but stops waiting
+// for an initializer once ctx is done or its Timeout() elapses, skips those not
+// yet started once ctx is done, and converts panics into errors identifying the
+// initializer and where it was registered. The duration of each initializer is
+// logged at debug level to args.Logger, if set.
+func CallInitializerFuncsContext(ctx context.Context, args InitializerArgs) error {
+	return initializers.CallContext(ctx, args)
 }
 
-func CallInitializerFuncs(args InitializerArgs) (err error) {
+// RegisterFunc registers an unnamed initializer with r.
+func (r *InitializerRegistry) RegisterFunc(f InitializerFunc) {
+	r.register("", f, nil)
+}
+
+// Register registers an initializer named name with r.
+func (r *InitializerRegistry) Register(name string, f InitializerFunc, opts ...InitializerOption) {
+	r.register(name, f, opts)
+}
+
+func (r *InitializerRegistry) register(name string, f InitializerFunc, opts []InitializerOption) {
+	site, pkg := caller(3)
+	i := &initializer{
+		name: name,
+		fn:   f,
+		site: site,
+		pkg:  pkg,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.initializers = append(r.initializers, i)
+}
+
+// snapshot returns a copy of the registered initializers so they can be called
+// without holding the lock, allowing initializers to register finalizers.
+func (r *InitializerRegistry) snapshot() []*initializer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.initializers)
+}
+
+// Initializers returns the initializers registered with r in the order Call()
+// will call them.
+func (r *InitializerRegistry) Initializers() (infos []InitializerInfo, err error) {
+	var ordered []*initializer
+
+	ordered, err = orderInitializers(r.snapshot())
+	if err != nil {
+		goto end
+	}
+	infos = make([]InitializerInfo, len(ordered))
+	for n, i := range ordered {
+		infos[n] = InitializerInfo{
+			Name:  i.name,
+			After: slices.Clone(i.after),
+			Site:  i.site,
+		}
+	}
+end:
+	return infos, err
+}
+
+// Call calls the initializers registered with r, each after those it depends on
+// and otherwise in registration order, returning their errors joined.
+func (r *InitializerRegistry) Call(args InitializerArgs) error {
+	return r.CallContext(context.Background(), args)
+}
+
+// CallContext is like Call but honors ctx as described for
+// CallInitializerFuncsContext().
+func (r *InitializerRegistry) CallContext(ctx context.Context, args InitializerArgs) (err error) {
 	var errs []error
-	for _, f := range initializerFuncs {
-		errs = append(errs, f(args))
+	var ordered []*initializer
+
+	ordered, err = orderInitializers(r.snapshot())
+	if err != nil {
+		goto end
+	}
+	if args.Logger != nil {
+		args.Logger = WithAppInfo(args.Logger, args.AppInfo, args.AppInfoMode)
+	}
+	for _, i := range ordered {
+		if ctx.Err() != nil {
+			errs = append(errs, dt.NewErr(ErrInitializerSkipped,
+				"initializer", i.String(),
+				"site", i.site,
+				ctx.Err(),
+			))
+			continue
+		}
+		err = i.call(ctx, args.scoped(i.pkg))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if i.finalizer != nil {
+			r.registerFinalizer(i.String(), i.finalizer)
+		}
+	}
+	err = errors.Join(errs...)
+end:
+	return err
+}
+
+// call calls the initializer, recovering any panic and abandoning it if ctx is
+// done or its timeout elapses before it returns.
+func (i *initializer) call(ctx context.Context, args InitializerArgs) (err error) {
+	var cancel context.CancelFunc
+
+	if i.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, i.timeout)
+		defer cancel()
+	}
+	args.Context = ctx
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			done <- dt.NewErr(ErrInitializerPanicked,
+				"initializer", i.String(),
+				"site", i.site,
+				"panic", fmt.Sprint(r),
+				"stack", string(debug.Stack()),
+			)
+		}()
+		done <- i.fn(args)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = dt.NewErr(ErrInitializerTimeout,
+			"initializer", i.String(),
+			"site", i.site,
+			ctx.Err(),
+		)
 	}
-	return errors.Join(errs...)
+	if args.Logger != nil {
+		args.Logger.Debug("Initializer completed",
+			"initializer", i.String(),
+			"duration", time.Since(start),
+			"failed", err != nil,
+		)
+	}
+	return err
 }
 
-// logger holds the structured logger instance for the golang package
-var logger *slog.Logger
+// orderInitializers sorts inits topologically by their declared dependencies,
+// breaking ties by registration order.
+func orderInitializers(inits []*initializer) (ordered []*initializer, err error) {
+	var done map[*initializer]bool
 
-// SetLogger sets the logger instance for the golang package and ensures it's valid
+	byName := make(map[string]*initializer, len(inits))
+	for _, i := range inits {
+		if i.name == "" {
+			continue
+		}
+		if prev, ok := byName[i.name]; ok {
+			err = dt.NewErr(ErrDuplicateInitializer,
+				"name", i.name,
+				"site", i.site,
+				"previous_site", prev.site,
+			)
+			goto end
+		}
+		byName[i.name] = i
+	}
+	for _, i := range inits {
+		for _, dep := range i.after {
+			if _, ok := byName[dep]; !ok {
+				err = dt.NewErr(ErrUnknownInitializer,
+					"initializer", i.String(),
+					"dependency", dep,
+				)
+				goto end
+			}
+		}
+	}
+
+	done = make(map[*initializer]bool, len(inits))
+	ordered = make([]*initializer, 0, len(inits))
+	for len(ordered) < len(inits) {
+		next := readyInitializer(inits, byName, done)
+		if next == nil {
+			err = dt.NewErr(ErrInitializerCycle,
+				"cycle", findInitializerCycle(inits, byName, done),
+			)
+			ordered = nil
+			goto end
+		}
+		done[next] = true
+		ordered = append(ordered, next)
+	}
+end:
+	return ordered, err
+}
+
+// readyInitializer returns the earliest registered initializer not yet done whose
+// dependencies are all done, or nil if there is none.
+func readyInitializer(inits []*initializer, byName map[string]*initializer, done map[*initializer]bool) *initializer {
+	for _, i := range inits {
+		if done[i] {
+			continue
+		}
+		ready := true
+		for _, dep := range i.after {
+			if !done[byName[dep]] {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			return i
+		}
+	}
+	return nil
+}
+
+// findInitializerCycle returns a description such as "a -> b -> a" of a cycle
+// among the initializers not yet done.
+func findInitializerCycle(inits []*initializer, byName map[string]*initializer, done map[*initializer]bool) string {
+	var path []*initializer
+	var visit func(i *initializer) []*initializer
+
+	onPath := make(map[*initializer]int)
+	visited := make(map[*initializer]bool)
+	visit = func(i *initializer) []*initializer {
+		if n, ok := onPath[i]; ok {
+			return append(path[n:len(path):len(path)], i)
+		}
+		if visited[i] || done[i] {
+			return nil
+		}
+		visited[i] = true
+		onPath[i] = len(path)
+		path = append(path, i)
+		for _, dep := range i.after {
+			cycle := visit(byName[dep])
+			if cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		delete(onPath, i)
+		return nil
+	}
+	for _, i := range inits {
+		cycle := visit(i)
+		if cycle == nil {
+			continue
+		}
+		names := make([]string, len(cycle))
+		for n, c := range cycle {
+			names[n] = c.String()
+		}
+		return strings.Join(names, " -> ")
+	}
+	return ""
+}
+
+// callerSite returns the file:line of the caller skip frames up the stack.
+func callerSite(skip int) string {
+	site, _ := caller(skip + 1)
+	return site
+}
+
+// caller returns the file:line and package import path of the caller skip frames
+// up the stack.
+func caller(skip int) (site, pkg string) {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		site = "unknown"
+		goto end
+	}
+	site = fmt.Sprintf("%s:%d", file, line)
+	pkg = funcPackage(runtime.FuncForPC(pc).Name())
+end:
+	return site, pkg
+}
+
+// funcPackage returns the package import path of a fully qualified function name
+// such as "github.com/user/repo/pkg.init.0" or "main.(*T).Method".
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return name
+	}
+	return name[:slash+1+dot]
+}
+
+// logger holds the structured logger instance for the logutil package
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger instance for the logutil package and ensures it's
+// valid. It is safe to call concurrently with Logger().
 func SetLogger(l *slog.Logger) {
-	logger = l
+	logger.Store(l)
 	ensureLogger()
 }
 
+// SwapLogger sets the logger instance for the logutil package to l, which may be
+// nil to unset it, and returns the previous logger or nil if none was set. Unlike
+// SetLogger() and Logger() it never panics, so it suits saving and restoring the
+// logger, e.g. in tests.
+func SwapLogger(l *slog.Logger) (prev *slog.Logger) {
+	return logger.Swap(l)
+}
+
+// Logger returns the logger set by SetLogger(), panicking if none has been set.
+func Logger() *slog.Logger {
+	return ensureLogger()
+}
+
 // ensureLogger panics if no logger has been set, preventing uninitialized usage
-func ensureLogger() {
-	if logger == nil {
-		panic("Must set logger with logutil.SetLogger() before using golang package")
+func ensureLogger() (l *slog.Logger) {
+	l = logger.Load()
+	if l == nil {
+		panic("Must set logger with logutil.SetLogger() before using logutil package")
 	}
+	return l
 }
 
 // init registers the logger initialization function
 func init() {
-	RegisterInitializerFunc(func(args InitializerArgs) error {
-		SetLogger(args.Logger)
+	RegisterInitializer("logutil", func(args InitializerArgs) error {
+		SetLogger(args.root)
 		return nil
 	})
 }
@@ -0,0 +1,218 @@
+package logutil
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var ErrJournalUnavailable = errors.New("journald socket unavailable")
+
+var (
+	_ slog.Handler = (*JournalHandler)(nil)
+	_ io.Closer    = (*JournalHandler)(nil)
+)
+
+// DefaultJournalSocket is the socket journald receives native protocol
+// messages on.
+const DefaultJournalSocket dt.Filepath = "/run/systemd/journal/socket"
+
+type JournalHandlerArgs struct {
+	// Socket is the journald socket written to; defaults to
+	// DefaultJournalSocket.
+	Socket dt.Filepath
+	// SyslogIdentifier sets the SYSLOG_IDENTIFIER field; defaults to the base
+	// name of the executable.
+	SyslogIdentifier string
+	// Level is the minimum level logged. Defaults to the level shared by
+	// logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	// RegisterFinalizer has CreateJournalLogger() queue the handler's Close()
+	// with RegisterFinalizerFunc() so CallFinalizerFuncs() closes the socket.
+	// Otherwise the caller closes it.
+	RegisterFinalizer bool
+}
+
+// JournalHandler is a slog.Handler writing records to journald using its native
+// protocol, so attributes are kept as journal fields rather than flattened into
+// the message. The message is written as MESSAGE, the level as PRIORITY (see
+// SyslogSeverityFor()) and the caller as CODE_FILE, CODE_LINE and CODE_FUNC.
+// Attribute keys are converted to field names as described by
+// JournalFieldName(), with groups joined by underscores, e.g. "req.path"
+// becomes REQ_PATH. Attributes whose field names collide with the fields above
+// are prefixed with USER_, e.g. "message" becomes USER_MESSAGE.
+type JournalHandler struct {
+	conn   *unixgramConn
+	args   JournalHandlerArgs
+	attrs  []flatAttr
+	groups []string
+}
+
+// NewJournalHandler returns a JournalHandler connected to the socket configured
+// by args, which may be nil.
+func NewJournalHandler(args *JournalHandlerArgs) (h *JournalHandler, err error) {
+	var c *unixgramConn
+
+	if args == nil {
+		args = &JournalHandlerArgs{}
+	}
+	a := *args
+	if a.Socket == "" {
+		a.Socket = DefaultJournalSocket
+	}
+	if a.SyslogIdentifier == "" {
+		a.SyslogIdentifier = filepath.Base(os.Args[0])
+	}
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	c = &unixgramConn{socket: a.Socket, unavailable: ErrJournalUnavailable}
+	err = c.dial()
+	if err != nil {
+		goto end
+	}
+	h = &JournalHandler{
+		conn: c,
+		args: a,
+	}
+end:
+	return h, err
+}
+
+// CreateJournalLogger creates a logger writing to journald. args may be nil; see
+// JournalHandlerArgs.RegisterFinalizer for closing the socket.
+func CreateJournalLogger(args *JournalHandlerArgs) (logger *slog.Logger, err error) {
+	var h *JournalHandler
+
+	h, err = NewJournalHandler(args)
+	if err != nil {
+		goto end
+	}
+	if args != nil && args.RegisterFinalizer {
+		RegisterFinalizerFunc(func(context.Context) error {
+			return h.Close()
+		})
+	}
+	logger = slog.New(h)
+end:
+	return logger, err
+}
+
+// journalReservedFields are the fields JournalHandler writes itself.
+var journalReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// JournalFieldName converts key to a journal field name: it is uppercased,
+// characters other than letters, digits and underscores are replaced with
+// underscores, leading underscores and digits are dropped as journald reserves
+// or rejects them, and it is truncated to 64 characters. It returns "" when
+// nothing is left.
+func JournalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.args.Level.Level()
+}
+
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	return h.conn.write(h.format(r))
+}
+
+// Close closes the socket; later writes fail.
+func (h *JournalHandler) Close() error {
+	return h.conn.close()
+}
+
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		h2.attrs = appendFlatAttrs(h2.attrs, h.groups, a)
+	}
+	return &h2
+}
+
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// format renders r as a native protocol datagram.
+func (h *JournalHandler) format(r slog.Record) (buf []byte) {
+	buf = appendJournalField(buf, "MESSAGE", r.Message)
+	buf = appendJournalField(buf, "PRIORITY", strconv.Itoa(int(SyslogSeverityFor(r.Level))))
+	buf = appendJournalField(buf, "SYSLOG_IDENTIFIER", h.args.SyslogIdentifier)
+	if r.PC != 0 {
+		src := r.Source()
+		buf = appendJournalField(buf, "CODE_FILE", src.File)
+		buf = appendJournalField(buf, "CODE_LINE", strconv.Itoa(src.Line))
+		buf = appendJournalField(buf, "CODE_FUNC", src.Function)
+	}
+	attrs := h.attrs[:len(h.attrs):len(h.attrs)]
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendFlatAttrs(attrs, h.groups, a)
+		return true
+	})
+	for _, a := range attrs {
+		name := JournalFieldName(a.key)
+		if name == "" {
+			continue
+		}
+		if journalReservedFields[name] {
+			name = "USER_" + name
+		}
+		buf = appendJournalField(buf, name, a.value)
+	}
+	return buf
+}
+
+// appendJournalField appends a field to buf as NAME=value, or, when value
+// contains a newline, in the binary form of the name, a newline, the value's
+// length as a little-endian uint64 and then the value.
+func appendJournalField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if !strings.Contains(value, "\n") {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
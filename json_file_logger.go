@@ -1,34 +1,141 @@
 package logutil
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log/slog"
 	"os"
+	"sync"
 
 	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-dt/appinfo"
 )
 
 var ErrDirIsOtherEntryType = errors.New("directory is other entry type")
 
-var _ dt.FilepathGetter = (*JSONHandler)(nil)
+var (
+	_ dt.FilepathGetter = (*JSONHandler)(nil)
+	_ io.Closer         = (*JSONHandler)(nil)
+)
 
 type JSONHandler struct {
 	*slog.JSONHandler
 	filepath dt.Filepath
+	file     *logFile
+}
+
+// logFile is the file written to by a JSONHandler and the handlers derived from
+// it by WithAttrs() and WithGroup().
+type logFile struct {
+	*os.File
+	once sync.Once
+	err  error
+}
+
+// Close closes the file; calling it again returns the result of the first call.
+func (f *logFile) Close() error {
+	f.once.Do(func() {
+		f.err = f.File.Close()
+	})
+	return f.err
 }
 
 func (h *JSONHandler) Filepath() dt.Filepath {
 	return h.filepath
 }
 
+// Close closes the log file. Pass JSONFileLoggerArgs.RegisterFinalizer to have
+// CallFinalizerFuncs() close it, or pair it with an initializer using the
+// Finalizer() option.
+func (h *JSONHandler) Close() error {
+	return h.file.Close()
+}
+
+func (h *JSONHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &JSONHandler{
+		JSONHandler: h.JSONHandler.WithAttrs(attrs).(*slog.JSONHandler),
+		filepath:    h.filepath,
+		file:        h.file,
+	}
+}
+
+func (h *JSONHandler) WithGroup(name string) slog.Handler {
+	return &JSONHandler{
+		JSONHandler: h.JSONHandler.WithGroup(name).(*slog.JSONHandler),
+		filepath:    h.filepath,
+		file:        h.file,
+	}
+}
+
+type JSONFileLoggerArgs struct {
+	// AppInfo, with AppInfoMode, identifies the application in the file; see
+	// AppInfoAttrs(). In AppInfoHeader mode the header record is written only
+	// when the file is new or empty, not when appending to an existing log.
+	AppInfo     appinfo.AppInfo
+	AppInfoMode AppInfoMode
+	// RegisterFinalizer queues the handler's Close() with RegisterFinalizerFunc()
+	// so CallFinalizerFuncs() closes the file. Otherwise the caller closes it.
+	RegisterFinalizer bool
+}
+
 // CreateJSONFileLogger creates a new structured logger that writes to a file. The logger
 // uses JSON format for structured logging.
 func CreateJSONFileLogger(file dt.Filepath) (logger *slog.Logger, err error) {
-	var w io.Writer
+	return CreateJSONFileLoggerWithArgs(file, nil)
+}
+
+// CreateJSONFileLoggerWithArgs is CreateJSONFileLogger() with options. args may
+// be nil.
+func CreateJSONFileLoggerWithArgs(file dt.Filepath, args *JSONFileLoggerArgs) (logger *slog.Logger, err error) {
+	var f *os.File
+	var h *JSONHandler
+	var info os.FileInfo
+	var handler slog.Handler
+
+	if args == nil {
+		args = &JSONFileLoggerArgs{}
+	}
+	err = ensureDir(file.Dir())
+	if err != nil {
+		goto end
+	}
+	f, err = file.OpenFile(os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		goto end
+	}
+	info, err = f.Stat()
+	if err != nil {
+		_ = f.Close()
+		goto end
+	}
+	h = &JSONHandler{
+		JSONHandler: slog.NewJSONHandler(f, &slog.HandlerOptions{
+			Level: levelVar,
+		}),
+		filepath: file,
+		file:     &logFile{File: f},
+	}
+	if args.RegisterFinalizer {
+		RegisterFinalizerFunc(func(context.Context) error {
+			return h.Close()
+		})
+	}
+	handler = h
+	if args.AppInfoMode != AppInfoHeader || info.Size() == 0 {
+		handler = withAppInfo(h, args.AppInfo, args.AppInfoMode)
+	}
+	logger = slog.New(handler)
+
+end:
+	return logger, err
+}
+
+// ensureDir creates dir if it does not exist, failing if something other than a
+// directory exists at its path.
+func ensureDir(dir dt.DirPath) (err error) {
 	var status dt.EntryStatus
 
-	dir := file.Dir()
 	status, err = dir.Status()
 	if err != nil {
 		goto end
@@ -44,25 +151,14 @@ func CreateJSONFileLogger(file dt.Filepath) (logger *slog.Logger, err error) {
 			"entry_type", status.String(),
 		)
 	}
-	if err != nil {
-		goto end
-	}
-	w, err = file.OpenFile(os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModePerm)
-	if err != nil {
-		goto end
-	}
-	logger = slog.New(&JSONHandler{
-		JSONHandler: slog.NewJSONHandler(w, &slog.HandlerOptions{}),
-		filepath:    file,
-	})
-
 end:
-	return logger, err
+	return err
 }
 
+// GetJSONFilepath returns the filepath of the JSON file logged to by logger, looking
+// through any middleware handlers wrapping the file handler.
 func GetJSONFilepath(logger *slog.Logger) (fp dt.Filepath) {
-	h := logger.Handler()
-	getter, ok := h.(dt.FilepathGetter)
+	getter, ok := findHandler[dt.FilepathGetter](logger.Handler())
 	if !ok {
 		panic("logger does not implement FilepathGetter")
 	}
@@ -0,0 +1,91 @@
+package logutil
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// levelVar is the level shared by the loggers created by logutil's constructors.
+var levelVar = new(slog.LevelVar)
+
+// levelMu serializes SetLevel() with the reverts scheduled by SetLevelFor().
+var levelMu sync.Mutex
+
+// levelGen counts the calls setting levelVar, so a revert scheduled by
+// SetLevelFor() can tell whether the level was set again since. Guarded by
+// levelMu.
+var levelGen uint64
+
+// LevelVar returns the slog.LevelVar shared by the loggers created by
+// CreateStderrTextLogger() and CreateJSONFileLogger(), for use with handlers
+// created elsewhere.
+func LevelVar() *slog.LevelVar {
+	return levelVar
+}
+
+// SetLevel sets the minimum level of the loggers created by logutil.
+func SetLevel(level slog.Level) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	levelGen++
+	levelVar.Set(level)
+}
+
+// GetLevel returns the minimum level of the loggers created by logutil.
+func GetLevel() slog.Level {
+	return levelVar.Level()
+}
+
+// SetLevelFor sets the level of the loggers created by logutil for d and then
+// reverts it to the level in effect before the call. Calling revert reverts it
+// immediately. The revert is skipped if SetLevel() or SetLevelFor() was called
+// in between, even with the same level, so a later setting is not undone.
+func SetLevelFor(level slog.Level, d time.Duration) (revert func()) {
+	var timer *time.Timer
+	var once sync.Once
+
+	levelMu.Lock()
+	prev := levelVar.Level()
+	levelGen++
+	gen := levelGen
+	levelVar.Set(level)
+	levelMu.Unlock()
+
+	restore := func() {
+		once.Do(func() {
+			levelMu.Lock()
+			defer levelMu.Unlock()
+			if levelGen == gen {
+				levelGen++
+				levelVar.Set(prev)
+			}
+		})
+	}
+	timer = time.AfterFunc(d, restore)
+	revert = func() {
+		timer.Stop()
+		restore()
+	}
+	return revert
+}
+
+// ParseLevel parses a level name such as "debug", "INFO", "warn", "warning",
+// "error" or one with an offset such as "info+2", case-insensitively.
+func ParseLevel(s string) (level slog.Level, err error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "WARNING") {
+		s = "WARN" + strings.TrimPrefix(s, "WARNING")
+	}
+	err = level.UnmarshalText([]byte(s))
+	if err != nil {
+		err = dt.NewErr(ErrInvalidLevel, "level", s, err)
+	}
+	return level, err
+}
@@ -0,0 +1,129 @@
+package logutil
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-dt/appinfo"
+)
+
+// LogFormat selects the output format of loggers created by CreateLogger().
+type LogFormat int
+
+const (
+	TextFormat LogFormat = iota
+	JSONFormat
+	ConsoleFormat
+)
+
+type LoggerArgs struct {
+	// Writer is where output is written; defaults to os.Stderr. To write to the
+	// error stream of a cliutil.Writer, pass InitializerArgs.Writer.ErrWriter().
+	Writer io.Writer
+	Format LogFormat
+	// Level is the minimum level logged. Defaults to the level shared by
+	// logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	// AddSource adds the caller's source location to each record.
+	AddSource bool
+	// SourceRoot is the directory source file paths are shown relative to when
+	// AddSource is true. Defaults to the current working directory. Paths outside
+	// it are shown in full.
+	SourceRoot dt.DirPath
+	// ReplaceAttr is called as documented for slog.HandlerOptions after source
+	// paths have been trimmed.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+	// Color controls color output when Format is ConsoleFormat.
+	Color ColorMode
+	// AppInfo, with AppInfoMode, identifies the application in the output; see
+	// AppInfoAttrs().
+	AppInfo     appinfo.AppInfo
+	AppInfoMode AppInfoMode
+}
+
+// CreateLogger creates a logger as configured by args, which may be nil to create
+// a text logger writing to stderr.
+func CreateLogger(args *LoggerArgs) *slog.Logger {
+	return slog.New(NewHandler(args))
+}
+
+// NewHandler creates the handler used by CreateLogger().
+func NewHandler(args *LoggerArgs) (h slog.Handler) {
+	if args == nil {
+		args = &LoggerArgs{}
+	}
+	a := *args
+	if a.Writer == nil {
+		a.Writer = os.Stderr
+	}
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	replace := a.ReplaceAttr
+	if a.AddSource {
+		replace = trimSourceFunc(a.SourceRoot, a.ReplaceAttr)
+	}
+	switch a.Format {
+	case JSONFormat:
+		h = slog.NewJSONHandler(a.Writer, &slog.HandlerOptions{
+			AddSource:   a.AddSource,
+			Level:       a.Level,
+			ReplaceAttr: replace,
+		})
+	case ConsoleFormat:
+		h = NewConsoleHandler(a.Writer, &ConsoleHandlerArgs{
+			Level:       a.Level,
+			Color:       a.Color,
+			AddSource:   a.AddSource,
+			ReplaceAttr: replace,
+		})
+	default:
+		h = slog.NewTextHandler(a.Writer, &slog.HandlerOptions{
+			AddSource:   a.AddSource,
+			Level:       a.Level,
+			ReplaceAttr: replace,
+		})
+	}
+	return withAppInfo(h, a.AppInfo, a.AppInfoMode)
+}
+
+// trimSourceFunc returns a ReplaceAttr function that makes source file paths
+// relative to root before calling next, if not nil.
+func trimSourceFunc(root dt.DirPath, next func([]string, slog.Attr) slog.Attr) func([]string, slog.Attr) slog.Attr {
+	if root == "" {
+		root, _ = dt.Getwd()
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.SourceKey {
+			a = trimSource(root, a)
+		}
+		if next != nil {
+			a = next(groups, a)
+		}
+		return a
+	}
+}
+
+func trimSource(root dt.DirPath, a slog.Attr) slog.Attr {
+	var rel string
+	var err error
+	var src2 slog.Source
+
+	src, ok := a.Value.Any().(*slog.Source)
+	if !ok || src == nil || root == "" {
+		goto end
+	}
+	rel, err = filepath.Rel(string(root), src.File)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		goto end
+	}
+	src2 = *src
+	src2.File = rel
+	a.Value = slog.AnyValue(&src2)
+end:
+	return a
+}
@@ -0,0 +1,191 @@
+package logutiltest
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Matcher matches a recorded log record, typically by one of its attributes.
+type Matcher interface {
+	Match(r Record) bool
+	String() string
+}
+
+type matcherFunc struct {
+	desc  string
+	match func(r Record) bool
+}
+
+func (m matcherFunc) Match(r Record) bool { return m.match(r) }
+func (m matcherFunc) String() string      { return m.desc }
+
+// HasAttr matches records having an attribute with key, using dotted keys for
+// attributes within groups.
+func HasAttr(key string) Matcher {
+	return matcherFunc{
+		desc: fmt.Sprintf("has %s", key),
+		match: func(r Record) bool {
+			_, ok := r.Attr(key)
+			return ok
+		},
+	}
+}
+
+// AttrEquals matches records having an attribute with key equal to value.
+func AttrEquals(key string, value any) Matcher {
+	want := slog.AnyValue(value).Resolve()
+	return matcherFunc{
+		desc: fmt.Sprintf("%s=%v", key, want),
+		match: func(r Record) bool {
+			got, ok := r.Attr(key)
+			return ok && valuesEqual(got, want)
+		},
+	}
+}
+
+// AttrContains matches records having an attribute with key whose string form
+// contains substr.
+func AttrContains(key, substr string) Matcher {
+	return matcherFunc{
+		desc: fmt.Sprintf("%s contains %q", key, substr),
+		match: func(r Record) bool {
+			got, ok := r.Attr(key)
+			return ok && strings.Contains(got.String(), substr)
+		},
+	}
+}
+
+// AttrMatches matches records having an attribute with key for which match
+// returns true.
+func AttrMatches(key string, match func(slog.Value) bool) Matcher {
+	return matcherFunc{
+		desc: fmt.Sprintf("%s matches func", key),
+		match: func(r Record) bool {
+			got, ok := r.Attr(key)
+			return ok && match(got)
+		},
+	}
+}
+
+// valuesEqual compares values, treating signed and unsigned integers of equal
+// value as equal so tests need not match the exact integer type logged.
+func valuesEqual(a, b slog.Value) bool {
+	if a.Equal(b) {
+		return true
+	}
+	switch {
+	case a.Kind() == slog.KindInt64 && b.Kind() == slog.KindUint64:
+		return a.Int64() >= 0 && uint64(a.Int64()) == b.Uint64()
+	case a.Kind() == slog.KindUint64 && b.Kind() == slog.KindInt64:
+		return b.Int64() >= 0 && uint64(b.Int64()) == a.Uint64()
+	}
+	return false
+}
+
+// toMatchers converts the arguments of RequireLogged() into matchers. Each
+// argument is a Matcher, a slog.Attr, or a key followed by its value.
+func toMatchers(args []any) (matchers []Matcher) {
+	for i := 0; i < len(args); i++ {
+		switch a := args[i].(type) {
+		case Matcher:
+			matchers = append(matchers, a)
+		case slog.Attr:
+			matchers = append(matchers, AttrEquals(a.Key, a.Value))
+		case string:
+			if i+1 >= len(args) {
+				matchers = append(matchers, HasAttr(a))
+				continue
+			}
+			matchers = append(matchers, AttrEquals(a, args[i+1]))
+			i++
+		default:
+			panic(fmt.Sprintf("logutiltest: unexpected matcher argument of type %T", a))
+		}
+	}
+	return matchers
+}
+
+// Find returns the first record with level and msg that satisfies every matcher
+// in args; see RequireLogged() for the arguments accepted.
+func (r *Recorder) Find(level slog.Level, msg string, args ...any) (rec Record, ok bool) {
+	matchers := toMatchers(args)
+	for _, rec = range r.Records() {
+		if rec.Level != level || rec.Message != msg {
+			continue
+		}
+		if matchesAll(rec, matchers) {
+			ok = true
+			break
+		}
+	}
+	return rec, ok
+}
+
+// RequireLogged fails t unless a record with level and msg was captured whose
+// attributes satisfy args. Each of args is a Matcher, a slog.Attr, or a key
+// followed by its expected value. Keys within groups are dotted, e.g. "req.id".
+func (r *Recorder) RequireLogged(t testing.TB, level slog.Level, msg string, args ...any) Record {
+	t.Helper()
+	rec, ok := r.Find(level, msg, args...)
+	if !ok {
+		t.Fatalf("logutiltest: expected %s record %q matching [%s]; got:\n%s",
+			level, msg, describe(toMatchers(args)), r.dump())
+	}
+	return rec
+}
+
+// RequireNotLogged fails t if any record with level and msg was captured.
+func (r *Recorder) RequireNotLogged(t testing.TB, level slog.Level, msg string) {
+	t.Helper()
+	if rec, ok := r.Find(level, msg); ok {
+		t.Fatalf("logutiltest: expected no %s record %q; got: %s", level, msg, rec)
+	}
+}
+
+// RequireLogged is like Recorder.RequireLogged() for the Recorder installed for t
+// by Install().
+func RequireLogged(t testing.TB, level slog.Level, msg string, args ...any) Record {
+	t.Helper()
+	return installedRecorder(t).RequireLogged(t, level, msg, args...)
+}
+
+// RequireNotLogged is like Recorder.RequireNotLogged() for the Recorder installed
+// for t by Install().
+func RequireNotLogged(t testing.TB, level slog.Level, msg string) {
+	t.Helper()
+	installedRecorder(t).RequireNotLogged(t, level, msg)
+}
+
+func matchesAll(rec Record, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !m.Match(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	descs := make([]string, len(matchers))
+	for i, m := range matchers {
+		descs[i] = m.String()
+	}
+	return strings.Join(descs, ", ")
+}
+
+// dump returns the captured records one per line for failure messages.
+func (r *Recorder) dump() string {
+	var sb strings.Builder
+	records := r.Records()
+	if len(records) == 0 {
+		return "  (no records)"
+	}
+	for _, rec := range records {
+		sb.WriteString("  ")
+		sb.WriteString(rec.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package logutiltest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// UpdateGolden causes RequireGolden() to write the golden file rather than
+// compare against it. It defaults to true when LOGUTILTEST_UPDATE is set; tests
+// may also set it from a flag such as -update.
+var UpdateGolden = os.Getenv("LOGUTILTEST_UPDATE") != ""
+
+// RequireGolden fails t unless got equals the contents of the golden file at
+// path, or writes got to path when UpdateGolden is true.
+func RequireGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if UpdateGolden {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("logutiltest: failed to update golden file %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("logutiltest: failed to read golden file %s (set UpdateGolden to create it): %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("logutiltest: output does not match golden file %s\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// JSON renders the captured records as JSON lines using slog.JSONHandler,
+// omitting their times so the output is stable enough for golden files.
+func (r *Recorder) JSON() []byte {
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, nil)
+	for _, rec := range r.Records() {
+		sr := slog.NewRecord(time.Time{}, rec.Level, rec.Message, 0)
+		sr.AddAttrs(rec.Attrs...)
+		_ = h.Handle(context.Background(), sr)
+	}
+	return buf.Bytes()
+}
+
+// RequireGoldenJSON compares the JSON rendering of the captured records against
+// the golden file at path; see RequireGolden().
+func (r *Recorder) RequireGoldenJSON(t testing.TB, path string) {
+	t.Helper()
+	RequireGolden(t, path, r.JSON())
+}
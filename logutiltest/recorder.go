@@ -0,0 +1,249 @@
+// Package logutiltest provides helpers for testing code that logs via log/slog
+// and logutil, recording log records so tests can make assertions about them.
+package logutiltest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+var _ slog.Handler = (*Recorder)(nil)
+
+// Record is a log record captured by a Recorder.
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attrs holds the record's attributes including those added with
+	// WithAttrs(), nested in the groups added with WithGroup().
+	Attrs []slog.Attr
+}
+
+// String formats r as a single line for test output.
+func (r Record) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Level.String())
+	sb.WriteByte(' ')
+	sb.WriteString(r.Message)
+	for _, a := range flattenAttrs("", r.Attrs) {
+		_, _ = fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value)
+	}
+	return sb.String()
+}
+
+// Attr returns the value of the attribute with key, using dotted keys such as
+// "req.id" for attributes within groups.
+func (r Record) Attr(key string) (v slog.Value, ok bool) {
+	for _, a := range flattenAttrs("", r.Attrs) {
+		if a.Key == key {
+			v, ok = a.Value, true
+			break
+		}
+	}
+	return v, ok
+}
+
+// recorderStore holds the records captured by a Recorder and the handlers
+// derived from it, and the level they capture.
+type recorderStore struct {
+	mu      sync.Mutex
+	records []Record
+	level   slog.Leveler
+	// t is cleared once t completes, as t.Log() then panics.
+	t testing.TB
+}
+
+// Recorder is a slog.Handler that captures records for later assertions and,
+// when created with a testing.TB, routes them to t.Log() so failing tests show
+// what was logged.
+type Recorder struct {
+	store *recorderStore
+	goas  []groupOrAttrs
+}
+
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewRecorder returns a Recorder capturing records at all levels. t may be nil to
+// not route records to t.Log(). Records handled after t completes are still
+// captured but no longer routed.
+func NewRecorder(t testing.TB) *Recorder {
+	store := &recorderStore{
+		level: slog.Level(-1 << 31),
+		t:     t,
+	}
+	if t != nil {
+		t.Cleanup(func() {
+			store.mu.Lock()
+			store.t = nil
+			store.mu.Unlock()
+		})
+	}
+	return &Recorder{store: store}
+}
+
+// SetLevel sets the minimum level captured, by default every level. It applies
+// to r and every handler derived from it.
+func (r *Recorder) SetLevel(level slog.Leveler) {
+	r.store.mu.Lock()
+	r.store.level = level
+	r.store.mu.Unlock()
+}
+
+// Logger returns a logger writing to r.
+func (r *Recorder) Logger() *slog.Logger {
+	return slog.New(r)
+}
+
+// Records returns the records captured so far.
+func (r *Recorder) Records() []Record {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return slices.Clone(r.store.records)
+}
+
+// Reset discards the records captured so far.
+func (r *Recorder) Reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.records = nil
+}
+
+func (r *Recorder) Enabled(_ context.Context, level slog.Level) bool {
+	r.store.mu.Lock()
+	minLevel := r.store.level
+	r.store.mu.Unlock()
+	return level >= minLevel.Level()
+}
+
+func (r *Recorder) Handle(_ context.Context, sr slog.Record) error {
+	attrs := make([]slog.Attr, 0, sr.NumAttrs())
+	sr.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	rec := Record{
+		Time:    sr.Time,
+		Level:   sr.Level,
+		Message: sr.Message,
+		Attrs:   r.nest(attrs),
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.records = append(r.store.records, rec)
+	if r.store.t != nil {
+		r.store.t.Log(rec.String())
+	}
+	return nil
+}
+
+func (r *Recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	r2 := *r
+	r2.goas = append(r.goas[:len(r.goas):len(r.goas)], groupOrAttrs{attrs: attrs})
+	return &r2
+}
+
+func (r *Recorder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	r2 := *r
+	r2.goas = append(r.goas[:len(r.goas):len(r.goas)], groupOrAttrs{group: name})
+	return &r2
+}
+
+// nest wraps attrs in the groups added with WithGroup(), prepending attributes
+// added with WithAttrs() at each level.
+func (r *Recorder) nest(attrs []slog.Attr) []slog.Attr {
+	for i := len(r.goas) - 1; i >= 0; i-- {
+		goa := r.goas[i]
+		if goa.group == "" {
+			attrs = append(goa.attrs[:len(goa.attrs):len(goa.attrs)], attrs...)
+			continue
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		attrs = []slog.Attr{slog.Group(goa.group, anySlice(attrs)...)}
+	}
+	return attrs
+}
+
+func anySlice(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return args
+}
+
+// flattenAttrs returns attrs with groups flattened into dotted keys and values
+// resolved.
+func flattenAttrs(prefix string, attrs []slog.Attr) (flat []slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() != slog.KindGroup {
+			flat = append(flat, slog.Attr{Key: prefix + a.Key, Value: a.Value})
+			continue
+		}
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		flat = append(flat, flattenAttrs(groupPrefix, a.Value.Group())...)
+	}
+	return flat
+}
+
+var (
+	installedMu sync.Mutex
+	installed   = make(map[testing.TB]*Recorder)
+)
+
+// Install creates a Recorder for t and sets it as the logutil package logger and
+// the slog default logger until t completes. Tests calling Install must not run
+// in parallel with other tests that log via those loggers.
+func Install(t testing.TB) *Recorder {
+	t.Helper()
+	rec := NewRecorder(t)
+	prevDefault := slog.Default()
+
+	logger := rec.Logger()
+	prevLogger := logutil.SwapLogger(logger)
+	slog.SetDefault(logger)
+
+	installedMu.Lock()
+	installed[t] = rec
+	installedMu.Unlock()
+
+	t.Cleanup(func() {
+		installedMu.Lock()
+		delete(installed, t)
+		installedMu.Unlock()
+		slog.SetDefault(prevDefault)
+		logutil.SwapLogger(prevLogger)
+	})
+	return rec
+}
+
+// installedRecorder returns the Recorder installed for t by Install().
+func installedRecorder(t testing.TB) *Recorder {
+	t.Helper()
+	installedMu.Lock()
+	rec, ok := installed[t]
+	installedMu.Unlock()
+	if !ok {
+		t.Fatalf("logutiltest: no Recorder installed for %s; call logutiltest.Install(t) first", t.Name())
+	}
+	return rec
+}
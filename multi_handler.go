@@ -0,0 +1,94 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+var _ slog.Handler = (*MultiHandler)(nil)
+
+// Sink is one destination of a MultiHandler.
+type Sink struct {
+	Handler slog.Handler
+	// Level is the minimum level of records dispatched to Handler. When nil the
+	// decision is left to Handler.Enabled().
+	Level slog.Leveler
+}
+
+// MultiHandler is a slog.Handler that dispatches each record to multiple sinks,
+// each with its own minimum level, e.g. warnings to stderr as text and debug
+// records to a JSON file:
+//
+//	logger := slog.New(logutil.NewMultiHandler(
+//		logutil.Sink{Handler: stderr.Handler(), Level: slog.LevelWarn},
+//		logutil.Sink{Handler: file.Handler(), Level: slog.LevelDebug},
+//	))
+type MultiHandler struct {
+	sinks []Sink
+}
+
+// NewMultiHandler returns a handler that fans records out to sinks.
+func NewMultiHandler(sinks ...Sink) *MultiHandler {
+	return &MultiHandler{sinks: sinks}
+}
+
+// Handlers returns the handlers of each sink.
+func (h *MultiHandler) Handlers() []slog.Handler {
+	handlers := make([]slog.Handler, len(h.sinks))
+	for i, s := range h.sinks {
+		handlers[i] = s.Handler
+	}
+	return handlers
+}
+
+func (h *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, s := range h.sinks {
+		if s.enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle dispatches r to every sink enabled for its level, returning the errors
+// of all sinks that failed combined with errors.Join().
+func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, s := range h.sinks {
+		if !s.enabled(ctx, r.Level) {
+			continue
+		}
+		err := s.Handler.Handle(ctx, r.Clone())
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = Sink{Handler: s.Handler.WithAttrs(attrs), Level: s.Level}
+	}
+	return &MultiHandler{sinks: sinks}
+}
+
+func (h *MultiHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = Sink{Handler: s.Handler.WithGroup(name), Level: s.Level}
+	}
+	return &MultiHandler{sinks: sinks}
+}
+
+func (s Sink) enabled(ctx context.Context, level slog.Level) bool {
+	if s.Level != nil {
+		return level >= s.Level.Level()
+	}
+	return s.Handler.Enabled(ctx, level)
+}
@@ -0,0 +1,226 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+// LoggerNameKey is the attribute key holding the name of a named logger.
+const LoggerNameKey = "logger"
+
+var ErrInvalidLevelSpec = errors.New("invalid level spec")
+
+var _ slog.Handler = (*NamedHandler)(nil)
+
+// namedLevels is the registry consulted by loggers returned from Named().
+var namedLevels = NewLevelRegistry()
+
+// Named returns a child of the package logger named name. Its level is controlled
+// by the patterns set with SetNamedLevel() or SetNamedLevels(), falling back to
+// the level of the package logger when no pattern matches name.
+func Named(name string) *slog.Logger {
+	return NamedLogger(ensureLogger(), name)
+}
+
+// NamedLogger returns a child of l named name whose level is controlled by the
+// package level registry.
+func NamedLogger(l *slog.Logger, name string) *slog.Logger {
+	return slog.New(NewNamedHandler(l.Handler(), name, namedLevels)).With(LoggerNameKey, name)
+}
+
+// SetNamedLevel sets the level for loggers whose names match pattern, which may
+// contain wildcards as supported by path.Match(), e.g. "http.*".
+func SetNamedLevel(pattern string, level slog.Level) error {
+	return namedLevels.Set(pattern, level)
+}
+
+// SetNamedLevels replaces all named levels with those in spec, a comma-separated
+// list of pattern=level pairs such as "db=debug,http.*=warn".
+func SetNamedLevels(spec string) error {
+	return namedLevels.Parse(spec)
+}
+
+// NamedLevel returns the level set for name and whether any pattern matched it.
+func NamedLevel(name string) (slog.Level, bool) {
+	return namedLevels.Level(name)
+}
+
+type levelRule struct {
+	pattern string
+	level   slog.Level
+}
+
+// LevelRegistry maps logger names to levels using exact names or wildcard
+// patterns. It is safe for concurrent use and may be changed at runtime.
+type LevelRegistry struct {
+	mu    sync.RWMutex
+	rules []levelRule
+}
+
+func NewLevelRegistry() *LevelRegistry {
+	return &LevelRegistry{}
+}
+
+// Set sets the level for names matching pattern, replacing any level previously
+// set for the same pattern.
+func (r *LevelRegistry) Set(pattern string, level slog.Level) (err error) {
+	err = validatePattern(pattern)
+	if err != nil {
+		goto end
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.rules {
+		if rule.pattern == pattern {
+			r.rules[i].level = level
+			goto end
+		}
+	}
+	r.rules = append(r.rules, levelRule{pattern: pattern, level: level})
+end:
+	return err
+}
+
+// Unset removes the level set for pattern.
+func (r *LevelRegistry) Unset(pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.rules {
+		if rule.pattern == pattern {
+			r.rules = append(r.rules[:i:i], r.rules[i+1:]...)
+			break
+		}
+	}
+}
+
+// Reset removes all levels.
+func (r *LevelRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = nil
+}
+
+// Parse replaces all levels with those in spec, a comma-separated list of
+// pattern=level pairs such as "db=debug,http.*=warn". On error the registry is
+// left unchanged.
+func (r *LevelRegistry) Parse(spec string) (err error) {
+	var rules []levelRule
+
+	for _, pair := range strings.Split(spec, ",") {
+		var level slog.Level
+
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, levelStr, found := strings.Cut(pair, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !found || pattern == "" {
+			err = dt.NewErr(ErrInvalidLevelSpec, "pair", pair)
+			goto end
+		}
+		err = validatePattern(pattern)
+		if err != nil {
+			goto end
+		}
+		level, err = ParseLevel(levelStr)
+		if err != nil {
+			err = dt.NewErr(ErrInvalidLevelSpec, "pair", pair, err)
+			goto end
+		}
+		rules = append(rules, levelRule{pattern: pattern, level: level})
+	}
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+end:
+	return err
+}
+
+// Level returns the level for name and whether any pattern matched it. An exact
+// match wins over wildcard patterns, and longer patterns win over shorter ones.
+func (r *LevelRegistry) Level(name string) (level slog.Level, ok bool) {
+	var best string
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rule := range r.rules {
+		if rule.pattern == name {
+			level, ok = rule.level, true
+			goto end
+		}
+		matched, _ := path.Match(rule.pattern, name)
+		if !matched {
+			continue
+		}
+		if ok && len(rule.pattern) <= len(best) {
+			continue
+		}
+		best = rule.pattern
+		level, ok = rule.level, true
+	}
+end:
+	return level, ok
+}
+
+// NamedHandler is a slog.Handler middleware whose level is looked up by name in a
+// LevelRegistry on every call, so levels can change without re-creating handlers.
+type NamedHandler struct {
+	handler  slog.Handler
+	name     string
+	registry *LevelRegistry
+}
+
+func NewNamedHandler(h slog.Handler, name string, registry *LevelRegistry) *NamedHandler {
+	return &NamedHandler{
+		handler:  h,
+		name:     name,
+		registry: registry,
+	}
+}
+
+// Name returns the name levels are looked up by.
+func (h *NamedHandler) Name() string {
+	return h.name
+}
+
+// Enabled reports whether level is at or above the level registered for the
+// handler's name, deferring to the wrapped handler when none is registered.
+func (h *NamedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel, ok := h.registry.Level(h.name)
+	if ok {
+		return level >= minLevel
+	}
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *NamedHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *NamedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewNamedHandler(h.handler.WithAttrs(attrs), h.name, h.registry)
+}
+
+func (h *NamedHandler) WithGroup(name string) slog.Handler {
+	return NewNamedHandler(h.handler.WithGroup(name), h.name, h.registry)
+}
+
+// Unwrap returns the handler wrapped by h.
+func (h *NamedHandler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+func validatePattern(pattern string) (err error) {
+	_, err = path.Match(pattern, "")
+	if err != nil {
+		err = dt.NewErr(ErrInvalidLevelSpec, "pattern", pattern, err)
+	}
+	return err
+}
@@ -0,0 +1,290 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+// otlpScopeName is the instrumentation scope of exported log records.
+const otlpScopeName = "github.com/mikeschinkel/go-logutil"
+
+// otlpRecord is a record captured by OTLPHandler for export.
+type otlpRecord struct {
+	time     time.Time
+	observed time.Time
+	level    slog.Level
+	msg      string
+	attrs    []slog.Attr
+	trace    TraceContext
+}
+
+// resolveOTLPAttrs returns attrs with LogValuers resolved, including within
+// groups, and values of KindAny other than []byte rendered as strings, so a
+// record can be exported later without racing callers that go on to modify
+// values they logged.
+func resolveOTLPAttrs(attrs []slog.Attr) []slog.Attr {
+	resolved := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		switch a.Value.Kind() {
+		case slog.KindGroup:
+			a.Value = slog.GroupValue(resolveOTLPAttrs(a.Value.Group())...)
+		case slog.KindAny:
+			if b, ok := a.Value.Any().([]byte); ok {
+				a.Value = slog.AnyValue(bytes.Clone(b))
+				break
+			}
+			a.Value = slog.StringValue(plainValue(a.Value))
+		}
+		resolved = append(resolved, a)
+	}
+	return resolved
+}
+
+// otlpSeverity maps level to an OpenTelemetry severity number, where DEBUG is 5,
+// INFO 9, WARN 13, ERROR 17 and FATAL 21, clamped to the valid range of 1-24.
+func otlpSeverity(level slog.Level) int {
+	return min(max(int(level)+9, 1), 24)
+}
+
+// encodeOTLPJSON encodes records as an ExportLogsServiceRequest in the OTLP JSON
+// encoding.
+func encodeOTLPJSON(resource []slog.Attr, records []otlpRecord) ([]byte, error) {
+	logRecords := make([]map[string]any, 0, len(records))
+	for _, rec := range records {
+		lr := map[string]any{
+			"timeUnixNano":         strconv.FormatInt(rec.time.UnixNano(), 10),
+			"observedTimeUnixNano": strconv.FormatInt(rec.observed.UnixNano(), 10),
+			"severityNumber":       otlpSeverity(rec.level),
+			"severityText":         rec.level.String(),
+			"body":                 map[string]any{"stringValue": rec.msg},
+		}
+		if len(rec.attrs) > 0 {
+			lr["attributes"] = otlpJSONKeyValues(rec.attrs)
+		}
+		if rec.trace.TraceID != "" {
+			lr["traceId"] = rec.trace.TraceID
+			lr["flags"] = int(rec.trace.Flags)
+		}
+		if rec.trace.SpanID != "" {
+			lr["spanId"] = rec.trace.SpanID
+		}
+		logRecords = append(logRecords, lr)
+	}
+	return json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpJSONKeyValues(resource),
+			},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": otlpScopeName},
+				"logRecords": logRecords,
+			}},
+		}},
+	})
+}
+
+func otlpJSONKeyValues(attrs []slog.Attr) []any {
+	kvs := make([]any, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		kvs = append(kvs, map[string]any{
+			"key":   a.Key,
+			"value": otlpJSONValue(a.Value),
+		})
+	}
+	return kvs
+}
+
+// otlpJSONValue encodes v as an OTLP AnyValue. As in the protobuf JSON mapping,
+// 64-bit integers are strings and non-finite floats are "NaN", "Infinity" or
+// "-Infinity".
+func otlpJSONValue(v slog.Value) (av map[string]any) {
+	switch v.Kind() {
+	case slog.KindString:
+		av = map[string]any{"stringValue": v.String()}
+	case slog.KindBool:
+		av = map[string]any{"boolValue": v.Bool()}
+	case slog.KindInt64:
+		av = map[string]any{"intValue": strconv.FormatInt(v.Int64(), 10)}
+	case slog.KindUint64:
+		if v.Uint64() > math.MaxInt64 {
+			av = map[string]any{"stringValue": strconv.FormatUint(v.Uint64(), 10)}
+			break
+		}
+		av = map[string]any{"intValue": strconv.FormatUint(v.Uint64(), 10)}
+	case slog.KindFloat64:
+		f := v.Float64()
+		switch {
+		case math.IsNaN(f):
+			av = map[string]any{"doubleValue": "NaN"}
+		case math.IsInf(f, 1):
+			av = map[string]any{"doubleValue": "Infinity"}
+		case math.IsInf(f, -1):
+			av = map[string]any{"doubleValue": "-Infinity"}
+		default:
+			av = map[string]any{"doubleValue": f}
+		}
+	case slog.KindDuration:
+		av = map[string]any{"intValue": strconv.FormatInt(int64(v.Duration()), 10)}
+	case slog.KindGroup:
+		av = map[string]any{"kvlistValue": map[string]any{
+			"values": otlpJSONKeyValues(v.Group()),
+		}}
+	case slog.KindAny:
+		if b, ok := v.Any().([]byte); ok {
+			av = map[string]any{"bytesValue": b}
+			break
+		}
+		av = map[string]any{"stringValue": plainValue(v)}
+	default:
+		av = map[string]any{"stringValue": plainValue(v)}
+	}
+	return av
+}
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// encodeOTLPProtobuf encodes records as an ExportLogsServiceRequest in the
+// protobuf encoding, following opentelemetry/proto/collector/logs/v1.
+func encodeOTLPProtobuf(resource []slog.Attr, records []otlpRecord) []byte {
+	var scopeLogs, resourceLogs, res []byte
+
+	// ScopeLogs: scope=1, log_records=2
+	scopeLogs = protoAppendMessage(scopeLogs, 1, protoAppendString(nil, 1, otlpScopeName))
+	for _, rec := range records {
+		scopeLogs = protoAppendMessage(scopeLogs, 2, protoLogRecord(rec))
+	}
+	// Resource: attributes=1
+	for _, a := range resource {
+		res = protoAppendKeyValue(res, 1, a)
+	}
+	// ResourceLogs: resource=1, scope_logs=2
+	resourceLogs = protoAppendMessage(resourceLogs, 1, res)
+	resourceLogs = protoAppendMessage(resourceLogs, 2, scopeLogs)
+	// ExportLogsServiceRequest: resource_logs=1
+	return protoAppendMessage(nil, 1, resourceLogs)
+}
+
+// protoLogRecord encodes rec as a LogRecord.
+func protoLogRecord(rec otlpRecord) (buf []byte) {
+	buf = protoAppendFixed64(buf, 1, uint64(rec.time.UnixNano()))
+	buf = protoAppendVarint(buf, 2, uint64(otlpSeverity(rec.level)))
+	buf = protoAppendString(buf, 3, rec.level.String())
+	buf = protoAppendMessage(buf, 5, protoAppendString(nil, 1, rec.msg))
+	for _, a := range rec.attrs {
+		buf = protoAppendKeyValue(buf, 6, a)
+	}
+	if id, err := hex.DecodeString(rec.trace.TraceID); err == nil && len(id) == 16 {
+		buf = protoAppendFixed32(buf, 8, uint32(rec.trace.Flags))
+		buf = protoAppendBytes(buf, 9, id)
+	}
+	if id, err := hex.DecodeString(rec.trace.SpanID); err == nil && len(id) == 8 {
+		buf = protoAppendBytes(buf, 10, id)
+	}
+	return protoAppendFixed64(buf, 11, uint64(rec.observed.UnixNano()))
+}
+
+// protoAppendKeyValue appends a as a KeyValue message: key=1, value=2.
+func protoAppendKeyValue(buf []byte, field int, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+	kv := protoAppendString(nil, 1, a.Key)
+	kv = protoAppendMessage(kv, 2, protoAnyValue(a.Value))
+	return protoAppendMessage(buf, field, kv)
+}
+
+// protoAnyValue encodes v as an AnyValue: string_value=1, bool_value=2,
+// int_value=3, double_value=4, kvlist_value=6, bytes_value=7.
+func protoAnyValue(v slog.Value) (buf []byte) {
+	switch v.Kind() {
+	case slog.KindString:
+		buf = protoAppendString(buf, 1, v.String())
+	case slog.KindBool:
+		b := uint64(0)
+		if v.Bool() {
+			b = 1
+		}
+		buf = protoAppendVarint(buf, 2, b)
+	case slog.KindInt64:
+		buf = protoAppendVarint(buf, 3, uint64(v.Int64()))
+	case slog.KindUint64:
+		if v.Uint64() > math.MaxInt64 {
+			buf = protoAppendString(buf, 1, strconv.FormatUint(v.Uint64(), 10))
+			break
+		}
+		buf = protoAppendVarint(buf, 3, v.Uint64())
+	case slog.KindFloat64:
+		buf = protoAppendFixed64(buf, 4, math.Float64bits(v.Float64()))
+	case slog.KindDuration:
+		buf = protoAppendVarint(buf, 3, uint64(v.Duration()))
+	case slog.KindGroup:
+		var kvs []byte
+		for _, a := range v.Group() {
+			kvs = protoAppendKeyValue(kvs, 1, a)
+		}
+		buf = protoAppendMessage(buf, 6, kvs)
+	case slog.KindAny:
+		if b, ok := v.Any().([]byte); ok {
+			buf = protoAppendBytes(buf, 7, b)
+			break
+		}
+		buf = protoAppendString(buf, 1, plainValue(v))
+	default:
+		buf = protoAppendString(buf, 1, plainValue(v))
+	}
+	return buf
+}
+
+func protoAppendTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func protoAppendVarint(buf []byte, field int, v uint64) []byte {
+	buf = protoAppendTag(buf, field, protoVarint)
+	return binary.AppendUvarint(buf, v)
+}
+
+func protoAppendFixed64(buf []byte, field int, v uint64) []byte {
+	buf = protoAppendTag(buf, field, protoFixed64)
+	return binary.LittleEndian.AppendUint64(buf, v)
+}
+
+func protoAppendFixed32(buf []byte, field int, v uint32) []byte {
+	buf = protoAppendTag(buf, field, protoFixed32)
+	return binary.LittleEndian.AppendUint32(buf, v)
+}
+
+func protoAppendBytes(buf []byte, field int, b []byte) []byte {
+	buf = protoAppendTag(buf, field, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func protoAppendString(buf []byte, field int, s string) []byte {
+	buf = protoAppendTag(buf, field, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// protoAppendMessage appends msg, an encoded message, as an embedded message.
+func protoAppendMessage(buf []byte, field int, msg []byte) []byte {
+	return protoAppendBytes(buf, field, msg)
+}
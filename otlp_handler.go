@@ -0,0 +1,292 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-dt/appinfo"
+)
+
+var _ slog.Handler = (*OTLPHandler)(nil)
+
+var (
+	_ flusher   = (*OTLPHandler)(nil)
+	_ io.Closer = (*OTLPHandler)(nil)
+)
+
+// OTLPEncoding selects the encoding OTLPHandler exports with.
+type OTLPEncoding int
+
+const (
+	OTLPProtobuf OTLPEncoding = iota
+	OTLPJSON
+)
+
+const (
+	DefaultOTLPEndpoint      = "http://localhost:4318/v1/logs"
+	DefaultOTLPBatchSize     = 512
+	DefaultOTLPBatchInterval = 5 * time.Second
+	DefaultMaxQueuedBatches  = 16
+)
+
+type OTLPHandlerArgs struct {
+	// Endpoint is the URL of the collector's logs endpoint; defaults to
+	// DefaultOTLPEndpoint.
+	Endpoint string
+	Encoding OTLPEncoding
+	// Headers are added to each export request, e.g. for authentication.
+	Headers map[string]string
+	// Gzip compresses export requests.
+	Gzip bool
+	// Client defaults to an http.Client with a timeout of DefaultExportTimeout.
+	Client *http.Client
+	// AppInfo, when set, provides the service.name and service.version resource
+	// attributes.
+	AppInfo appinfo.AppInfo
+	// ResourceAttrs are added to the resource attributes.
+	ResourceAttrs []slog.Attr
+	// TraceExtractor supplies the trace and span IDs of records; defaults to
+	// TraceContextFromContext.
+	TraceExtractor TraceExtractor
+	// Level is the minimum level exported. Defaults to the level shared by
+	// logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	// AddSource adds the code.file.path, code.line.number and code.function.name
+	// attributes identifying the caller.
+	AddSource bool
+	// BatchSize is the number of records exported together; defaults to
+	// DefaultOTLPBatchSize.
+	BatchSize int
+	// BatchInterval is how often a partial batch is exported; defaults to
+	// DefaultOTLPBatchInterval.
+	BatchInterval time.Duration
+	// MaxQueuedBatches is the number of batches held while the collector is slow
+	// or unreachable before the oldest is dropped or written to FallbackFile;
+	// defaults to DefaultMaxQueuedBatches.
+	MaxQueuedBatches int
+	Backoff          BackoffArgs
+	// FallbackFile, when set, receives batches that could not be exported after
+	// retrying, as lines of OTLP JSON as written by the collector's file exporter.
+	FallbackFile dt.Filepath
+	// OnError, when set, is called with errors exporting in the background or
+	// writing FallbackFile.
+	OnError func(err error)
+	// RegisterFinalizer has CreateOTLPLogger() queue the handler's Shutdown()
+	// with RegisterFinalizerFunc() so CallFinalizerFuncs() exports the remaining
+	// records. Otherwise the caller closes the handler.
+	RegisterFinalizer bool
+}
+
+// OTLPHandler is a slog.Handler exporting records to an OpenTelemetry collector
+// using OTLP/HTTP. Records are batched and exported in the background, so call
+// Close(), or CallFinalizerFuncs() when registered by CreateOTLPLogger(), to
+// export what remains before exiting.
+type OTLPHandler struct {
+	chain    attrChain
+	exporter *otlpExporter
+}
+
+// otlpExporter is shared by an OTLPHandler and the handlers derived from it by
+// WithAttrs() and WithGroup().
+type otlpExporter struct {
+	args     OTLPHandlerArgs
+	resource []slog.Attr
+	http     *httpExporter
+	batcher  *batcher[otlpRecord]
+	fileMu   sync.Mutex
+}
+
+// NewOTLPHandler returns an OTLPHandler configured by args, which may be nil.
+func NewOTLPHandler(args *OTLPHandlerArgs) *OTLPHandler {
+	if args == nil {
+		args = &OTLPHandlerArgs{}
+	}
+	a := *args
+	if a.Endpoint == "" {
+		a.Endpoint = DefaultOTLPEndpoint
+	}
+	if a.Client == nil {
+		a.Client = &http.Client{Timeout: DefaultExportTimeout}
+	}
+	if a.TraceExtractor == nil {
+		a.TraceExtractor = TraceContextFromContext
+	}
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	if a.BatchSize <= 0 {
+		a.BatchSize = DefaultOTLPBatchSize
+	}
+	if a.BatchInterval <= 0 {
+		a.BatchInterval = DefaultOTLPBatchInterval
+	}
+	if a.MaxQueuedBatches <= 0 {
+		a.MaxQueuedBatches = DefaultMaxQueuedBatches
+	}
+	a.Backoff = a.Backoff.withDefaults()
+
+	e := &otlpExporter{
+		args:     a,
+		resource: otlpResource(a),
+		http: &httpExporter{
+			client:      a.Client,
+			url:         a.Endpoint,
+			contentType: "application/x-protobuf",
+			headers:     a.Headers,
+			gzip:        a.Gzip,
+		},
+	}
+	if a.Encoding == OTLPJSON {
+		e.http.contentType = "application/json"
+	}
+	e.batcher = newBatcher(batcherArgs[otlpRecord]{
+		maxItems:  a.BatchSize,
+		interval:  a.BatchInterval,
+		maxQueued: a.MaxQueuedBatches,
+		export:    e.export,
+		drop:      e.fallback,
+	})
+	return &OTLPHandler{exporter: e}
+}
+
+// CreateOTLPLogger creates a logger exporting to an OpenTelemetry collector.
+// args may be nil; see OTLPHandlerArgs.RegisterFinalizer for exporting the
+// remaining records.
+func CreateOTLPLogger(args *OTLPHandlerArgs) *slog.Logger {
+	h := NewOTLPHandler(args)
+	if h.exporter.args.RegisterFinalizer {
+		RegisterFinalizerFunc(h.Shutdown)
+	}
+	return slog.New(h)
+}
+
+// otlpResource returns the resource attributes configured by args.
+func otlpResource(args OTLPHandlerArgs) (attrs []slog.Attr) {
+	if args.AppInfo != nil {
+		attrs = append(attrs, slog.String("service.name", args.AppInfo.Name()))
+		if v := args.AppInfo.Version(); v != "" {
+			attrs = append(attrs, slog.String("service.version", string(v)))
+		}
+	}
+	return append(attrs, args.ResourceAttrs...)
+}
+
+func (h *OTLPHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.exporter.args.Level.Level()
+}
+
+func (h *OTLPHandler) Handle(ctx context.Context, r slog.Record) error {
+	rec := otlpRecord{
+		time:     r.Time,
+		observed: time.Now(),
+		level:    r.Level,
+		msg:      r.Message,
+		attrs:    resolveOTLPAttrs(h.chain.nest(recordAttrs(r))),
+	}
+	if rec.time.IsZero() {
+		rec.time = rec.observed
+	}
+	if h.exporter.args.AddSource && r.PC != 0 {
+		src := r.Source()
+		rec.attrs = append(rec.attrs,
+			slog.String("code.file.path", src.File),
+			slog.Int("code.line.number", src.Line),
+			slog.String("code.function.name", src.Function),
+		)
+	}
+	rec.trace, _ = h.exporter.args.TraceExtractor(ctx)
+	h.exporter.batcher.add(rec)
+	return nil
+}
+
+func (h *OTLPHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &OTLPHandler{
+		chain:    h.chain.withAttrs(attrs),
+		exporter: h.exporter,
+	}
+}
+
+func (h *OTLPHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &OTLPHandler{
+		chain:    h.chain.withGroup(name),
+		exporter: h.exporter,
+	}
+}
+
+// Flush exports the records batched so far.
+func (h *OTLPHandler) Flush(ctx context.Context) error {
+	return h.exporter.batcher.flush(ctx)
+}
+
+// Shutdown stops background exporting and exports the records that remain,
+// giving up when ctx is done.
+func (h *OTLPHandler) Shutdown(ctx context.Context) error {
+	return h.exporter.batcher.close(ctx)
+}
+
+// Close calls Shutdown() allowing DefaultExportTimeout.
+func (h *OTLPHandler) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultExportTimeout)
+	defer cancel()
+	return h.Shutdown(ctx)
+}
+
+// export sends batch to the collector, retrying as configured.
+func (e *otlpExporter) export(ctx context.Context, batch []otlpRecord) (err error) {
+	var body []byte
+
+	if e.args.Encoding == OTLPJSON {
+		body, err = encodeOTLPJSON(e.resource, batch)
+		if err != nil {
+			goto end
+		}
+	} else {
+		body = encodeOTLPProtobuf(e.resource, batch)
+	}
+	err = retry(ctx, e.args.Backoff, func(ctx context.Context) error {
+		return e.http.post(ctx, body)
+	})
+end:
+	if err != nil && e.args.OnError != nil {
+		e.args.OnError(err)
+	}
+	return err
+}
+
+// fallback appends batch, which failed to export or was dropped from the queue,
+// to FallbackFile, if set, as a line of OTLP JSON.
+func (e *otlpExporter) fallback(batch []otlpRecord, cause error) {
+	var body []byte
+	var err error
+
+	if e.args.FallbackFile == "" {
+		if errors.Is(cause, ErrBatchQueueFull) || errors.Is(cause, ErrExporterClosed) {
+			// Export failures were reported by export().
+			err = cause
+		}
+		goto end
+	}
+	body, err = encodeOTLPJSON(e.resource, batch)
+	if err != nil {
+		goto end
+	}
+	e.fileMu.Lock()
+	err = appendLines(e.args.FallbackFile, [][]byte{append(body, '\n')})
+	e.fileMu.Unlock()
+end:
+	if err != nil && e.args.OnError != nil {
+		e.args.OnError(dt.NewErr(ErrExportFailed, "fallback_file", e.args.FallbackFile, "records", len(batch), errors.Join(cause, err)))
+	}
+}
@@ -0,0 +1,93 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+var _ slog.Handler = (*packageHandler)(nil)
+
+// PackageLogger returns a logger for use as a package-level variable by the
+// calling package:
+//
+//	var logger = logutil.PackageLogger()
+//
+// It is populated automatically by CallInitializerFuncs() with the application's
+// logger scoped to the calling package via a PackageKey attribute. Until then it
+// logs to slog.Default().
+func PackageLogger() *slog.Logger {
+	target := &atomic.Pointer[slog.Logger]{}
+	initializers.register("", func(args InitializerArgs) error {
+		target.Store(args.Logger)
+		return nil
+	}, nil)
+	return slog.New(&packageHandler{target: target})
+}
+
+// packageHandler forwards to the handler of the logger stored in target, applying
+// any WithAttrs() and WithGroup() calls made before target was populated.
+type packageHandler struct {
+	target *atomic.Pointer[slog.Logger]
+	chain  attrChain
+	cache  atomic.Pointer[resolvedHandler]
+}
+
+// resolvedHandler caches the handler derived from a target logger.
+type resolvedHandler struct {
+	logger  *slog.Logger
+	handler slog.Handler
+}
+
+// handler returns the handler to forward to, deriving it again only when the
+// target logger changes.
+func (h *packageHandler) handler() slog.Handler {
+	l := h.target.Load()
+	if l == nil {
+		l = slog.Default()
+	}
+	cached := h.cache.Load()
+	if cached != nil && cached.logger == l {
+		return cached.handler
+	}
+	derived := l.Handler()
+	for _, goa := range h.chain {
+		if goa.group != "" {
+			derived = derived.WithGroup(goa.group)
+			continue
+		}
+		derived = derived.WithAttrs(goa.attrs)
+	}
+	h.cache.Store(&resolvedHandler{logger: l, handler: derived})
+	return derived
+}
+
+func (h *packageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
+
+func (h *packageHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &packageHandler{
+		target: h.target,
+		chain:  h.chain.withAttrs(attrs),
+	}
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &packageHandler{
+		target: h.target,
+		chain:  h.chain.withGroup(name),
+	}
+}
+
+// Unwrap returns the handler currently forwarded to.
+func (h *packageHandler) Unwrap() slog.Handler {
+	return h.handler()
+}
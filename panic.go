@@ -0,0 +1,156 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// LevelFatal is the level of records logged by Fatal().
+const LevelFatal = slog.LevelError + 4
+
+// PanicMessage is the default message of records logged by RecoverAndLog().
+const PanicMessage = "Panic recovered"
+
+// DefaultFlushTimeout bounds how long RecoverAndLog() and Fatal() spend flushing
+// and closing handlers, so a crash is not held up by an unreachable collector.
+const DefaultFlushTimeout = 2 * time.Second
+
+// flusher is implemented by handlers that hold records or summaries back, such
+// as SamplingHandler and DedupHandler.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// shutdowner is implemented by closable handlers whose closing honors a
+// deadline, such as OTLPHandler and HTTPBatchHandler.
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+type RecoverArgs struct {
+	// Logger receives the panic record; defaults to the logger set by
+	// SetLogger(), or slog.Default() if none has been set.
+	Logger *slog.Logger
+	// Message is the message of the panic record; defaults to PanicMessage.
+	Message string
+	// RePanic re-panics with the recovered value after logging.
+	RePanic bool
+	// ExitCode, when not zero and RePanic is false, exits the process with it
+	// after logging.
+	ExitCode int
+	// FlushTimeout bounds flushing and closing handlers; defaults to
+	// DefaultFlushTimeout.
+	FlushTimeout time.Duration
+}
+
+// RecoverAndLog recovers a panic and logs its value and the goroutine's stack.
+// Any records buffered by a RingBufferHandler with a DumpHandler found in the
+// logger's handler are dumped to it first for context, and handlers that hold
+// records back are flushed within FlushTimeout. Handlers that write files or
+// ship records are also closed when exiting, but left open otherwise, so logging
+// can continue. It must be deferred directly:
+//
+//	defer logutil.RecoverAndLog(&logutil.RecoverArgs{ExitCode: 2})
+//
+// args may be nil to log the panic and continue.
+func RecoverAndLog(args *RecoverArgs) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if args == nil {
+		args = &RecoverArgs{}
+	}
+	l := args.Logger
+	if l == nil {
+		l = defaultLogger()
+	}
+	msg := args.Message
+	if msg == "" {
+		msg = PanicMessage
+	}
+	timeout := args.FlushTimeout
+	if timeout <= 0 {
+		timeout = DefaultFlushTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	dumpRingBuffers(ctx, l)
+	l.Error(msg,
+		"panic", fmt.Sprint(r),
+		"stack", string(debug.Stack()),
+	)
+	switch {
+	case args.RePanic:
+		_ = flushHandlers(ctx, l)
+		panic(r)
+	case args.ExitCode != 0:
+		_ = closeHandlers(ctx, l)
+		os.Exit(args.ExitCode)
+	default:
+		_ = flushHandlers(ctx, l)
+	}
+}
+
+// Fatal logs msg and args at LevelFatal to the logger set by SetLogger(), dumps
+// any buffered records, flushes and closes handlers as RecoverAndLog() does
+// within DefaultFlushTimeout, and then exits the process with status 1.
+func Fatal(msg string, args ...any) {
+	l := defaultLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultFlushTimeout)
+	dumpRingBuffers(ctx, l)
+	l.Log(ctx, LevelFatal, msg, args...)
+	_ = closeHandlers(ctx, l)
+	cancel()
+	os.Exit(1)
+}
+
+// defaultLogger returns the logger set by SetLogger(), or slog.Default().
+func defaultLogger() *slog.Logger {
+	l := logger.Load()
+	if l == nil {
+		l = slog.Default()
+	}
+	return l
+}
+
+// dumpRingBuffers dumps any RingBufferHandlers within l's handler that have a
+// DumpHandler to it. Those without one are left alone, as dumping them to l's
+// handler would drop the records below its level.
+func dumpRingBuffers(ctx context.Context, l *slog.Logger) {
+	for _, ring := range collectHandlers[*RingBufferHandler](l.Handler()) {
+		if ring.ring.args.DumpHandler == nil {
+			continue
+		}
+		_ = ring.DumpTo(ctx, ring.ring.args.DumpHandler)
+	}
+}
+
+// flushHandlers flushes every handler within l's handler that supports it.
+func flushHandlers(ctx context.Context, l *slog.Logger) error {
+	var errs []error
+	for _, f := range collectHandlers[flusher](l.Handler()) {
+		errs = append(errs, f.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// closeHandlers flushes and then closes every handler within l's handler that
+// supports it, for use just before exiting.
+func closeHandlers(ctx context.Context, l *slog.Logger) error {
+	errs := []error{flushHandlers(ctx, l)}
+	for _, c := range collectHandlers[io.Closer](l.Handler()) {
+		if s, ok := c.(shutdowner); ok {
+			errs = append(errs, s.Shutdown(ctx))
+			continue
+		}
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
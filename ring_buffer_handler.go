@@ -0,0 +1,197 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var _ slog.Handler = (*RingBufferHandler)(nil)
+
+const (
+	DefaultRingBufferSize = 1000
+
+	// ReplayedKey is the attribute added to records replayed from a
+	// RingBufferHandler, distinguishing them from the same records logged live.
+	ReplayedKey = "replayed"
+)
+
+type RingBufferHandlerArgs struct {
+	// Size is the number of records kept; defaults to DefaultRingBufferSize.
+	Size int
+	// Level is the minimum level kept; defaults to slog.LevelDebug regardless of
+	// the level of other handlers.
+	Level slog.Leveler
+	// DumpLevel, when not nil, causes the buffer to be dumped to DumpHandler
+	// whenever a record at or above it is handled.
+	DumpLevel slog.Leveler
+	// DumpHandler receives the buffered records when DumpLevel is reached, and
+	// when RecoverAndLog() or Fatal() is called with a logger containing the
+	// handler. Records are passed to its Handle() without checking its level.
+	DumpHandler slog.Handler
+}
+
+// RingBufferHandler is a slog.Handler that keeps the most recent records in
+// memory, including debug records, so they can be dumped for context when an
+// error or panic occurs. Combine it with the main sink using MultiHandler:
+//
+//	ring := logutil.NewRingBufferHandler(&logutil.RingBufferHandlerArgs{
+//		DumpLevel:   slog.LevelError,
+//		DumpHandler: file.Handler(),
+//	})
+//	logger := slog.New(logutil.NewMultiHandler(
+//		logutil.Sink{Handler: file.Handler(), Level: slog.LevelInfo},
+//		logutil.Sink{Handler: ring},
+//	))
+type RingBufferHandler struct {
+	chain attrChain
+	ring  *ringBuffer
+}
+
+// replayingKey is the context key marking records being dumped from the
+// ringBuffer it holds, so they are not buffered again by that buffer.
+type replayingKey struct{}
+
+type ringBuffer struct {
+	mu      sync.Mutex
+	args    RingBufferHandlerArgs
+	records []slog.Record
+	next    int
+	full    bool
+}
+
+// NewRingBufferHandler returns a handler buffering records as configured by args,
+// which may be nil.
+func NewRingBufferHandler(args *RingBufferHandlerArgs) *RingBufferHandler {
+	if args == nil {
+		args = &RingBufferHandlerArgs{}
+	}
+	a := *args
+	if a.Size <= 0 {
+		a.Size = DefaultRingBufferSize
+	}
+	if a.Level == nil {
+		a.Level = slog.LevelDebug
+	}
+	return &RingBufferHandler{
+		ring: &ringBuffer{
+			args:    a,
+			records: make([]slog.Record, a.Size),
+		},
+	}
+}
+
+func (h *RingBufferHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.ring.args.Level.Level()
+}
+
+// Handle buffers r, first dumping the buffer if r is at or above DumpLevel.
+func (h *RingBufferHandler) Handle(ctx context.Context, r slog.Record) (err error) {
+	a := h.ring.args
+	if a.DumpLevel != nil && a.DumpHandler != nil && r.Level >= a.DumpLevel.Level() {
+		err = h.DumpTo(ctx, a.DumpHandler)
+	}
+	if ctx.Value(replayingKey{}) != h.ring {
+		nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		nr.AddAttrs(h.chain.nest(recordAttrs(r))...)
+		h.ring.add(nr)
+	}
+	return err
+}
+
+func (h *RingBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RingBufferHandler{
+		chain: h.chain.withAttrs(attrs),
+		ring:  h.ring,
+	}
+}
+
+func (h *RingBufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &RingBufferHandler{
+		chain: h.chain.withGroup(name),
+		ring:  h.ring,
+	}
+}
+
+// Records returns the buffered records, oldest first.
+func (h *RingBufferHandler) Records() []slog.Record {
+	return h.ring.snapshot(false)
+}
+
+// Reset discards the buffered records.
+func (h *RingBufferHandler) Reset() {
+	h.ring.snapshot(true)
+}
+
+// DumpTo replays the buffered records, oldest first, to target with a ReplayedKey
+// attribute and empties the buffer. Should target contain h, the records are not
+// buffered again.
+func (h *RingBufferHandler) DumpTo(ctx context.Context, target slog.Handler) error {
+	var errs []error
+	ctx = context.WithValue(ctx, replayingKey{}, h.ring)
+	for _, r := range h.ring.snapshot(true) {
+		r.AddAttrs(slog.Bool(ReplayedKey, true))
+		errs = append(errs, target.Handle(ctx, r))
+	}
+	return errors.Join(errs...)
+}
+
+// DumpToLogger replays the buffered records to l's handler; see DumpTo(). Records
+// below the levels of l's handlers, as applied by MultiHandler, are dropped, so
+// prefer DumpTo() with a handler accepting debug records.
+func (h *RingBufferHandler) DumpToLogger(ctx context.Context, l *slog.Logger) error {
+	return h.DumpTo(ctx, l.Handler())
+}
+
+// DumpToFile appends the buffered records to file as JSON using
+// CreateJSONFileLogger(); see DumpTo().
+func (h *RingBufferHandler) DumpToFile(ctx context.Context, file dt.Filepath) (err error) {
+	var l *slog.Logger
+	var jh *JSONHandler
+
+	l, err = CreateJSONFileLogger(file)
+	if err != nil {
+		goto end
+	}
+	jh = l.Handler().(*JSONHandler)
+	err = h.DumpTo(ctx, jh)
+	err = errors.Join(err, jh.Close())
+end:
+	return err
+}
+
+func (b *ringBuffer) add(r slog.Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records[b.next] = r
+	b.next++
+	if b.next == len(b.records) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// snapshot returns the buffered records oldest first, emptying the buffer if reset.
+func (b *ringBuffer) snapshot(reset bool) (records []slog.Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.full {
+		records = append(records, b.records[b.next:]...)
+	}
+	records = append(records, b.records[:b.next]...)
+	for i, r := range records {
+		records[i] = r.Clone()
+	}
+	if reset {
+		clear(b.records)
+		b.next = 0
+		b.full = false
+	}
+	return records
+}
@@ -0,0 +1,322 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	_ slog.Handler = (*SamplingHandler)(nil)
+	_ flusher      = (*SamplingHandler)(nil)
+	_ io.Closer    = (*SamplingHandler)(nil)
+)
+
+// SamplingSummaryMessage is the message of the records SamplingHandler emits to
+// report how many records it suppressed.
+const SamplingSummaryMessage = "Log records suppressed by sampling"
+
+const (
+	DefaultSamplingInterval   = time.Second
+	DefaultSamplingFirst      = 100
+	DefaultSamplingThereafter = 100
+)
+
+// SamplingRule limits the records passed per (level, message) per interval to the
+// First records and then every Thereafter-th record. When Thereafter is zero all
+// records after the First are dropped. The zero SamplingRule disables sampling.
+type SamplingRule struct {
+	First      int
+	Thereafter int
+}
+
+func (r SamplingRule) disabled() bool {
+	return r.First <= 0 && r.Thereafter <= 0
+}
+
+// pass reports whether the n-th record of an interval is passed.
+func (r SamplingRule) pass(n int) bool {
+	switch {
+	case r.disabled():
+		return true
+	case n <= r.First:
+		return true
+	case r.Thereafter <= 0:
+		return false
+	}
+	return (n-r.First)%r.Thereafter == 0
+}
+
+type SamplingHandlerArgs struct {
+	// Interval is the period over which records are counted; defaults to
+	// DefaultSamplingInterval.
+	Interval time.Duration
+	// Rule applies to levels without an entry in LevelRules. When nil args are
+	// passed it defaults to DefaultSamplingFirst and DefaultSamplingThereafter.
+	Rule SamplingRule
+	// LevelRules overrides Rule for specific levels.
+	LevelRules map[slog.Level]SamplingRule
+	// UnsampledLevel is the level at and above which records are never sampled;
+	// defaults to slog.LevelError.
+	UnsampledLevel slog.Leveler
+	// Now returns the current time and is used to measure the interval, which
+	// the timer emitting summaries then waits on in real time; defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// SamplingHandler is a slog.Handler middleware that caps high-volume messages.
+// For each (level, message) it passes records as allowed by its SamplingRule and
+// drops the rest, and once the interval has elapsed it emits a summary record
+// reporting how many were suppressed. Summaries are emitted by the next record
+// handled or, failing that, by a timer started when a record is first
+// suppressed, through the handler that handled the key's first record. The
+// timer stops once nothing is pending; Close() stops it early and emits pending
+// summaries.
+type SamplingHandler struct {
+	handler slog.Handler
+	state   *samplingState
+}
+
+type samplingState struct {
+	mu          sync.Mutex
+	args        SamplingHandlerArgs
+	windowStart time.Time
+	counts      map[samplingKey]*samplingCount
+	timer       *time.Timer
+	armed       bool
+}
+
+type samplingKey struct {
+	level slog.Level
+	msg   string
+}
+
+type samplingCount struct {
+	n          int
+	suppressed int
+	// handler handled the first record of the key and emits its summaries.
+	handler slog.Handler
+}
+
+// samplingSummary is a summary record and the handler to emit it with.
+type samplingSummary struct {
+	record  slog.Record
+	handler slog.Handler
+}
+
+// NewSamplingHandler wraps h with sampling configured by args, which may be nil
+// for the defaults.
+func NewSamplingHandler(h slog.Handler, args *SamplingHandlerArgs) *SamplingHandler {
+	if args == nil {
+		args = &SamplingHandlerArgs{
+			Rule: SamplingRule{
+				First:      DefaultSamplingFirst,
+				Thereafter: DefaultSamplingThereafter,
+			},
+		}
+	}
+	a := *args
+	if a.Interval <= 0 {
+		a.Interval = DefaultSamplingInterval
+	}
+	if a.UnsampledLevel == nil {
+		a.UnsampledLevel = slog.LevelError
+	}
+	if a.Now == nil {
+		a.Now = time.Now
+	}
+	return &SamplingHandler{
+		handler: h,
+		state: &samplingState{
+			args:        a,
+			windowStart: a.Now(),
+			counts:      make(map[samplingKey]*samplingCount),
+		},
+	}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) (err error) {
+	var summaries []samplingSummary
+	var errs []error
+	var pass bool
+
+	s := h.state
+	rule := s.rule(r.Level)
+	if rule.disabled() {
+		err = h.handler.Handle(ctx, r)
+		goto end
+	}
+	summaries, pass = s.count(r, rule, h.handler)
+	errs = append(errs, emitSummaries(ctx, summaries))
+	if pass {
+		errs = append(errs, h.handler.Handle(ctx, r))
+	}
+	err = errors.Join(errs...)
+end:
+	return err
+}
+
+// Flush emits summary records for records suppressed since the last summary.
+func (h *SamplingHandler) Flush(ctx context.Context) error {
+	s := h.state
+	s.mu.Lock()
+	summaries := s.summaries(s.args.Now())
+	for _, c := range s.counts {
+		c.suppressed = 0
+	}
+	s.mu.Unlock()
+	return emitSummaries(ctx, summaries)
+}
+
+// Close stops the timer emitting summaries and emits those pending.
+func (h *SamplingHandler) Close() error {
+	s := h.state
+	s.mu.Lock()
+	if s.armed {
+		s.timer.Stop()
+		s.armed = false
+	}
+	s.mu.Unlock()
+	return h.Flush(context.Background())
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{
+		handler: h.handler.WithAttrs(attrs),
+		state:   h.state,
+	}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{
+		handler: h.handler.WithGroup(name),
+		state:   h.state,
+	}
+}
+
+// Unwrap returns the handler wrapped by h.
+func (h *SamplingHandler) Unwrap() slog.Handler {
+	return h.handler
+}
+
+// emitSummaries emits each summary with its handler.
+func emitSummaries(ctx context.Context, summaries []samplingSummary) error {
+	var errs []error
+	for _, ss := range summaries {
+		errs = append(errs, ss.handler.Handle(ctx, ss.record))
+	}
+	return errors.Join(errs...)
+}
+
+// armLocked starts the timer to fire when the current interval ends, unless it
+// is already running. The caller must hold s.mu.
+func (s *samplingState) armLocked(now time.Time) {
+	if s.armed {
+		return
+	}
+	s.armed = true
+	s.timer = time.AfterFunc(s.args.Interval-now.Sub(s.windowStart), s.expire)
+}
+
+// expire emits the summaries of the interval if it has ended and restarts the
+// timer while suppressed records remain unreported.
+func (s *samplingState) expire() {
+	s.mu.Lock()
+	s.armed = false
+	now := s.args.Now()
+	summaries := s.rolloverLocked(now)
+	if s.pendingLocked() {
+		s.armLocked(now)
+	}
+	s.mu.Unlock()
+	_ = emitSummaries(context.Background(), summaries)
+}
+
+// rule returns the sampling rule for level.
+func (s *samplingState) rule(level slog.Level) (rule SamplingRule) {
+	var ok bool
+
+	if level >= s.args.UnsampledLevel.Level() {
+		goto end
+	}
+	rule, ok = s.args.LevelRules[level]
+	if !ok {
+		rule = s.args.Rule
+	}
+end:
+	return rule
+}
+
+// count counts r, handled by h, against its (level, message) key, returning
+// whether it passes and the summaries of the previous interval if it has
+// elapsed.
+func (s *samplingState) count(r slog.Record, rule SamplingRule, h slog.Handler) (summaries []samplingSummary, pass bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.args.Now()
+	summaries = s.rolloverLocked(now)
+	key := samplingKey{level: r.Level, msg: r.Message}
+	c, ok := s.counts[key]
+	if !ok {
+		c = &samplingCount{handler: h}
+		s.counts[key] = c
+	}
+	c.n++
+	pass = rule.pass(c.n)
+	if !pass {
+		c.suppressed++
+		s.armLocked(now)
+	}
+	return summaries, pass
+}
+
+// rolloverLocked starts a new interval if the current one has elapsed, returning
+// the summaries of the one ended. The caller must hold s.mu.
+func (s *samplingState) rolloverLocked(now time.Time) (summaries []samplingSummary) {
+	if now.Sub(s.windowStart) < s.args.Interval {
+		goto end
+	}
+	summaries = s.summaries(now)
+	s.counts = make(map[samplingKey]*samplingCount)
+	s.windowStart = now
+end:
+	return summaries
+}
+
+// pendingLocked reports whether any suppressed records are yet to be summarized.
+// The caller must hold s.mu.
+func (s *samplingState) pendingLocked() bool {
+	for _, c := range s.counts {
+		if c.suppressed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// summaries returns summary records for keys with suppressed records. The caller
+// must hold s.mu.
+func (s *samplingState) summaries(now time.Time) (summaries []samplingSummary) {
+	for key, c := range s.counts {
+		if c.suppressed == 0 {
+			continue
+		}
+		r := slog.NewRecord(now, key.level, SamplingSummaryMessage, 0)
+		r.AddAttrs(
+			slog.String("sampled_msg", key.msg),
+			slog.Int("suppressed", c.suppressed),
+			slog.Int("total", c.n),
+			slog.Duration("interval", now.Sub(s.windowStart)),
+		)
+		summaries = append(summaries, samplingSummary{record: r, handler: c.handler})
+	}
+	return summaries
+}
@@ -0,0 +1,528 @@
+package logutil
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var (
+	ErrInvalidSpoolCheckpoint = errors.New("invalid spool checkpoint")
+	ErrSpoolClosed            = errors.New("spool closed")
+)
+
+var (
+	_ slog.Handler = (*SpoolHandler)(nil)
+	_ io.Closer    = (*SpoolHandler)(nil)
+)
+
+const (
+	DefaultSpoolSegmentBytes = 8 << 20
+
+	// SpoolCheckpointFile is the file within a spool directory recording the
+	// position acknowledged by its reader.
+	SpoolCheckpointFile = "checkpoint.json"
+)
+
+const (
+	// sealedSegmentExt is the extension of segments no longer written to.
+	sealedSegmentExt = ".jsonl"
+	// openSegmentExt is the extension of the segment being written to.
+	openSegmentExt = ".jsonl.open"
+)
+
+type SpoolArgs struct {
+	// MaxSegmentBytes is the size segments are rotated at; defaults to
+	// DefaultSpoolSegmentBytes.
+	MaxSegmentBytes int64
+	// Sync calls fsync after each record rather than only when a segment is
+	// sealed, trading throughput for durability across power loss.
+	Sync bool
+	// Level is the minimum level spooled by a SpoolHandler. Defaults to the level
+	// shared by logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	// RegisterFinalizer has CreateSpoolLogger() queue the writer's Close() with
+	// RegisterFinalizerFunc() so CallFinalizerFuncs() seals the open segment.
+	// Otherwise the open segment is sealed by the next writer.
+	RegisterFinalizer bool
+}
+
+// SpoolWriter appends JSON lines to a write-ahead spool directory of numbered
+// segments, for processes that must log while disconnected and ship later using
+// a SpoolReader. The segment being written has a .jsonl.open extension and is
+// sealed by renaming it to .jsonl when it fills, when the writer is closed, or,
+// after a crash, when the next writer opens the directory. Only one writer may
+// use a directory at a time.
+type SpoolWriter struct {
+	mu     sync.Mutex
+	dir    dt.DirPath
+	args   SpoolArgs
+	seq    uint64
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// OpenSpoolWriter opens dir for writing, creating it if needed. Writing starts in
+// a new segment, numbered after both the segments present and the checkpoint so
+// a reader never resumes inside it even when every segment has been
+// acknowledged and deleted. args may be nil.
+func OpenSpoolWriter(dir dt.DirPath, args *SpoolArgs) (w *SpoolWriter, err error) {
+	var segs []spoolSegment
+	var acked SpoolPosition
+
+	if args == nil {
+		args = &SpoolArgs{}
+	}
+	w = &SpoolWriter{dir: dir, args: *args}
+	if w.args.MaxSegmentBytes <= 0 {
+		w.args.MaxSegmentBytes = DefaultSpoolSegmentBytes
+	}
+	err = ensureDir(dir)
+	if err != nil {
+		goto end
+	}
+	segs, err = listSpoolSegments(dir)
+	if err != nil {
+		goto end
+	}
+	for _, seg := range segs {
+		if seg.open {
+			// Left behind by a writer that did not close.
+			err = os.Rename(string(seg.path(dir)), string(spoolSegment{seq: seg.seq}.path(dir)))
+			if err != nil {
+				goto end
+			}
+		}
+		w.seq = seg.seq
+	}
+	acked, err = readSpoolCheckpoint(dir)
+	if err != nil {
+		goto end
+	}
+	w.seq = max(w.seq, acked.Segment)
+	err = w.rotate()
+end:
+	if err != nil {
+		w = nil
+	}
+	return w, err
+}
+
+// Write appends p, which should be a single newline-terminated JSON record, to
+// the open segment, rotating first if p would make it exceed MaxSegmentBytes.
+func (w *SpoolWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		err = ErrSpoolClosed
+		goto end
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.args.MaxSegmentBytes {
+		err = w.rotate()
+		if err != nil {
+			goto end
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	if err == nil && w.args.Sync {
+		err = w.file.Sync()
+	}
+end:
+	return n, err
+}
+
+// Close seals the open segment.
+func (w *SpoolWriter) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		goto end
+	}
+	w.closed = true
+	err = w.seal()
+end:
+	return err
+}
+
+// rotate seals the open segment, if any, and opens the next.
+func (w *SpoolWriter) rotate() (err error) {
+	err = w.seal()
+	if err != nil {
+		goto end
+	}
+	w.seq++
+	w.file, err = spoolSegment{seq: w.seq, open: true}.path(w.dir).
+		OpenFile(os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	w.size = 0
+end:
+	return err
+}
+
+// seal syncs, closes and renames the open segment.
+func (w *SpoolWriter) seal() (err error) {
+	if w.file == nil {
+		goto end
+	}
+	err = errors.Join(w.file.Sync(), w.file.Close())
+	w.file = nil
+	if err != nil {
+		goto end
+	}
+	err = os.Rename(
+		string(spoolSegment{seq: w.seq, open: true}.path(w.dir)),
+		string(spoolSegment{seq: w.seq}.path(w.dir)),
+	)
+end:
+	return err
+}
+
+// SpoolHandler is a slog.Handler writing JSON records to a SpoolWriter.
+type SpoolHandler struct {
+	*slog.JSONHandler
+	writer *SpoolWriter
+}
+
+// NewSpoolHandler returns a SpoolHandler writing to w. args may be nil; only
+// its Level is used.
+func NewSpoolHandler(w *SpoolWriter, args *SpoolArgs) *SpoolHandler {
+	var level slog.Leveler = levelVar
+	if args != nil && args.Level != nil {
+		level = args.Level
+	}
+	return &SpoolHandler{
+		JSONHandler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}),
+		writer:      w,
+	}
+}
+
+// CreateSpoolLogger creates a logger spooling JSON records to dir. args may be
+// nil; see SpoolArgs.RegisterFinalizer for sealing the open segment.
+func CreateSpoolLogger(dir dt.DirPath, args *SpoolArgs) (logger *slog.Logger, err error) {
+	var w *SpoolWriter
+
+	w, err = OpenSpoolWriter(dir, args)
+	if err != nil {
+		goto end
+	}
+	if w.args.RegisterFinalizer {
+		RegisterFinalizerFunc(func(context.Context) error {
+			return w.Close()
+		})
+	}
+	logger = slog.New(NewSpoolHandler(w, args))
+end:
+	return logger, err
+}
+
+// Close closes the SpoolWriter.
+func (h *SpoolHandler) Close() error {
+	return h.writer.Close()
+}
+
+func (h *SpoolHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SpoolHandler{
+		JSONHandler: h.JSONHandler.WithAttrs(attrs).(*slog.JSONHandler),
+		writer:      h.writer,
+	}
+}
+
+func (h *SpoolHandler) WithGroup(name string) slog.Handler {
+	return &SpoolHandler{
+		JSONHandler: h.JSONHandler.WithGroup(name).(*slog.JSONHandler),
+		writer:      h.writer,
+	}
+}
+
+// SpoolPosition identifies a point in a spool: a byte offset within a segment.
+type SpoolPosition struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// SpoolRecord is a record read from a spool.
+type SpoolRecord struct {
+	// Data is the JSON record without its trailing newline.
+	Data []byte
+	// Next is the position following the record; pass it to Ack() once the
+	// record has been shipped.
+	Next SpoolPosition
+}
+
+// SpoolReader reads the records of a spool directory for shipping, resuming
+// from the position last acknowledged, even by an earlier process. It may be
+// used while a SpoolWriter appends to the same directory.
+type SpoolReader struct {
+	mu  sync.Mutex
+	dir dt.DirPath
+	// acked is the position recorded in the checkpoint file.
+	acked SpoolPosition
+	// pos is the position the next Read() starts at.
+	pos SpoolPosition
+}
+
+// OpenSpoolReader opens dir for reading from its checkpoint.
+func OpenSpoolReader(dir dt.DirPath) (r *SpoolReader, err error) {
+	r = &SpoolReader{dir: dir}
+	r.acked, err = readSpoolCheckpoint(dir)
+	if err != nil {
+		r = nil
+		goto end
+	}
+	r.pos = r.acked
+end:
+	return r, err
+}
+
+// readSpoolCheckpoint returns the position recorded in dir's checkpoint, or the
+// zero position when there is none.
+func readSpoolCheckpoint(dir dt.DirPath) (pos SpoolPosition, err error) {
+	var data []byte
+
+	file := spoolCheckpointFile(dir)
+	data, err = file.ReadFile()
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+		goto end
+	}
+	if err != nil {
+		goto end
+	}
+	err = json.Unmarshal(data, &pos)
+	if err != nil {
+		err = dt.NewErr(ErrInvalidSpoolCheckpoint, "file", file, err)
+	}
+end:
+	return pos, err
+}
+
+// Acked returns the position last acknowledged.
+func (r *SpoolReader) Acked() SpoolPosition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.acked
+}
+
+// Read returns up to max records following those previously read, or none when
+// the reader has caught up. A partially written record at the end of the open
+// segment is left for a later Read(); one at the end of a sealed segment, left
+// by a crash, is skipped.
+func (r *SpoolReader) Read(max int) (records []SpoolRecord, err error) {
+	var segs []spoolSegment
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	segs, err = listSpoolSegments(r.dir)
+	if err != nil {
+		goto end
+	}
+	for _, seg := range segs {
+		if seg.seq < r.pos.Segment || len(records) >= max {
+			continue
+		}
+		if seg.seq > r.pos.Segment {
+			r.pos = SpoolPosition{Segment: seg.seq}
+		}
+		records, err = r.readSegment(seg, records, max)
+		if err != nil {
+			goto end
+		}
+	}
+end:
+	return records, err
+}
+
+// readSegment appends records from seg, starting at r.pos, until max is reached
+// or the segment is exhausted.
+func (r *SpoolReader) readSegment(seg spoolSegment, records []SpoolRecord, max int) (_ []SpoolRecord, err error) {
+	var f *os.File
+	var br *bufio.Reader
+	var line []byte
+
+	f, err = seg.path(r.dir).Open()
+	if errors.Is(err, os.ErrNotExist) && seg.open {
+		// Sealed since it was listed.
+		seg.open = false
+		f, err = seg.path(r.dir).Open()
+	}
+	if err != nil {
+		goto end
+	}
+	_, err = f.Seek(r.pos.Offset, io.SeekStart)
+	if err != nil {
+		goto end
+	}
+	br = bufio.NewReader(f)
+	for len(records) < max {
+		line, err = br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			err = nil
+			if len(line) > 0 && !seg.open {
+				// Torn by a crash; skip it.
+				r.pos.Offset += int64(len(line))
+			}
+			break
+		}
+		if err != nil {
+			goto end
+		}
+		r.pos.Offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		records = append(records, SpoolRecord{
+			Data: bytes.TrimSuffix(line, []byte{'\n'}),
+			Next: r.pos,
+		})
+	}
+end:
+	if f != nil {
+		_ = f.Close()
+	}
+	return records, err
+}
+
+// Rewind makes the next Read() start at the position last acknowledged, e.g.
+// after shipping records failed.
+func (r *SpoolReader) Rewind() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pos = r.acked
+}
+
+// Ack records that the records before pos have been shipped: pos is written to
+// the checkpoint file, replacing it atomically, and sealed segments that are
+// wholly before pos are deleted.
+func (r *SpoolReader) Ack(pos SpoolPosition) (err error) {
+	var data []byte
+	var segs []spoolSegment
+	var info os.FileInfo
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err = json.Marshal(pos)
+	if err != nil {
+		goto end
+	}
+	err = writeFileAtomic(r.checkpointFile(), data)
+	if err != nil {
+		goto end
+	}
+	r.acked = pos
+	if r.pos.Segment < pos.Segment || r.pos.Segment == pos.Segment && r.pos.Offset < pos.Offset {
+		r.pos = pos
+	}
+	segs, err = listSpoolSegments(r.dir)
+	if err != nil {
+		goto end
+	}
+	for _, seg := range segs {
+		if seg.open || seg.seq > pos.Segment {
+			break
+		}
+		if seg.seq == pos.Segment {
+			info, err = seg.path(r.dir).Stat()
+			if err != nil || info.Size() > pos.Offset {
+				break
+			}
+		}
+		err = seg.path(r.dir).Remove()
+		if err != nil {
+			break
+		}
+	}
+end:
+	return err
+}
+
+func (r *SpoolReader) checkpointFile() dt.Filepath {
+	return spoolCheckpointFile(r.dir)
+}
+
+func spoolCheckpointFile(dir dt.DirPath) dt.Filepath {
+	return dt.Filepath(filepath.Join(string(dir), SpoolCheckpointFile))
+}
+
+// spoolSegment identifies a segment file by its sequence number.
+type spoolSegment struct {
+	seq  uint64
+	open bool
+}
+
+func (s spoolSegment) path(dir dt.DirPath) dt.Filepath {
+	ext := sealedSegmentExt
+	if s.open {
+		ext = openSegmentExt
+	}
+	return dt.Filepath(filepath.Join(string(dir), fmt.Sprintf("%020d%s", s.seq, ext)))
+}
+
+// listSpoolSegments returns the segments in dir in order.
+func listSpoolSegments(dir dt.DirPath) (segs []spoolSegment, err error) {
+	var entries []os.DirEntry
+
+	entries, err = dir.ReadDir()
+	if err != nil {
+		goto end
+	}
+	for _, e := range entries {
+		var seg spoolSegment
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, openSegmentExt):
+			seg.open = true
+			name = strings.TrimSuffix(name, openSegmentExt)
+		case strings.HasSuffix(name, sealedSegmentExt):
+			name = strings.TrimSuffix(name, sealedSegmentExt)
+		default:
+			continue
+		}
+		seg.seq, err = strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			// Not a segment.
+			err = nil
+			continue
+		}
+		segs = append(segs, seg)
+	}
+	slices.SortFunc(segs, func(a, b spoolSegment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+end:
+	return segs, err
+}
+
+// writeFileAtomic replaces file with data by writing and syncing a temporary
+// file and renaming it over file.
+func writeFileAtomic(file dt.Filepath, data []byte) (err error) {
+	var f *os.File
+
+	tmp := file + ".tmp"
+	f, err = tmp.OpenFile(os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		goto end
+	}
+	_, err = f.Write(data)
+	err = errors.Join(err, f.Sync(), f.Close())
+	if err != nil {
+		goto end
+	}
+	err = os.Rename(string(tmp), string(file))
+end:
+	return err
+}
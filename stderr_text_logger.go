@@ -2,13 +2,12 @@ package logutil
 
 import (
 	"log/slog"
-	"os"
 )
 
+// CreateStderrTextLogger creates a text logger writing to stderr. Use
+// CreateLogger() to configure the writer, format, level or source locations.
 func CreateStderrTextLogger() *slog.Logger {
-	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		AddSource:   false,
-		Level:       nil,
-		ReplaceAttr: nil,
-	}))
+	return CreateLogger(&LoggerArgs{
+		Format: TextFormat,
+	})
 }
@@ -0,0 +1,325 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+var ErrSyslogUnavailable = errors.New("syslog socket unavailable")
+
+var (
+	_ slog.Handler = (*SyslogHandler)(nil)
+	_ io.Closer    = (*SyslogHandler)(nil)
+)
+
+// SyslogFormat selects the syslog message format written by SyslogHandler.
+type SyslogFormat int
+
+const (
+	// RFC5424 formats messages per RFC 5424, with attributes as structured data.
+	RFC5424 SyslogFormat = iota
+	// RFC3164 formats messages in the traditional BSD format of RFC 3164, with
+	// attributes appended to the message as key=value pairs.
+	RFC3164
+)
+
+// SyslogFacility is a syslog facility code.
+type SyslogFacility int
+
+const (
+	FacilityUser   SyslogFacility = 1
+	FacilityDaemon SyslogFacility = 3
+	FacilityAuth   SyslogFacility = 4
+	FacilityLocal0 SyslogFacility = 16
+	FacilityLocal1 SyslogFacility = 17
+	FacilityLocal2 SyslogFacility = 18
+	FacilityLocal3 SyslogFacility = 19
+	FacilityLocal4 SyslogFacility = 20
+	FacilityLocal5 SyslogFacility = 21
+	FacilityLocal6 SyslogFacility = 22
+	FacilityLocal7 SyslogFacility = 23
+)
+
+// SyslogSeverity is a syslog severity code.
+type SyslogSeverity int
+
+const (
+	SeverityEmergency SyslogSeverity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+const (
+	// DefaultSyslogSocket is the socket of the local syslog daemon.
+	DefaultSyslogSocket dt.Filepath = "/dev/log"
+
+	// DefaultSyslogSDID is the SD-ID of the structured data element holding
+	// attributes in RFC 5424 messages. 32473 is the enterprise number reserved
+	// for documentation by RFC 5612.
+	DefaultSyslogSDID = "attrs@32473"
+)
+
+type SyslogHandlerArgs struct {
+	// Socket is the Unix datagram socket written to; defaults to
+	// DefaultSyslogSocket.
+	Socket dt.Filepath
+	Format SyslogFormat
+	// Facility defaults to FacilityUser; the kernel facility is reserved.
+	Facility SyslogFacility
+	// AppName defaults to the base name of the executable.
+	AppName string
+	// Hostname defaults to os.Hostname().
+	Hostname string
+	// SDID is the SD-ID of the element holding attributes in RFC 5424 messages;
+	// defaults to DefaultSyslogSDID.
+	SDID string
+	// Level is the minimum level logged. Defaults to the level shared by
+	// logutil's loggers; see SetLevel().
+	Level slog.Leveler
+	// RegisterFinalizer has CreateSyslogLogger() queue the handler's Close()
+	// with RegisterFinalizerFunc() so CallFinalizerFuncs() closes the socket.
+	// Otherwise the caller closes it.
+	RegisterFinalizer bool
+}
+
+// SyslogHandler is a slog.Handler writing each record as a datagram to the local
+// syslog daemon. Levels map to severities as described by SyslogSeverityFor().
+// Should a write fail, e.g. because the daemon restarted, the socket is redialed
+// and the write retried once.
+type SyslogHandler struct {
+	conn   *unixgramConn
+	args   SyslogHandlerArgs
+	pid    string
+	params []flatAttr
+	groups []string
+}
+
+// NewSyslogHandler returns a SyslogHandler connected to the socket configured by
+// args, which may be nil.
+func NewSyslogHandler(args *SyslogHandlerArgs) (h *SyslogHandler, err error) {
+	var c *unixgramConn
+
+	if args == nil {
+		args = &SyslogHandlerArgs{}
+	}
+	a := *args
+	if a.Socket == "" {
+		a.Socket = DefaultSyslogSocket
+	}
+	if a.Facility == 0 {
+		a.Facility = FacilityUser
+	}
+	if a.AppName == "" {
+		a.AppName = filepath.Base(os.Args[0])
+	}
+	if a.Hostname == "" {
+		a.Hostname, _ = os.Hostname()
+	}
+	if a.SDID == "" {
+		a.SDID = DefaultSyslogSDID
+	}
+	if a.Level == nil {
+		a.Level = levelVar
+	}
+	c = &unixgramConn{socket: a.Socket, unavailable: ErrSyslogUnavailable}
+	err = c.dial()
+	if err != nil {
+		goto end
+	}
+	h = &SyslogHandler{
+		conn: c,
+		args: a,
+		pid:  strconv.Itoa(os.Getpid()),
+	}
+end:
+	return h, err
+}
+
+// CreateSyslogLogger creates a logger writing to the local syslog daemon. args
+// may be nil; see SyslogHandlerArgs.RegisterFinalizer for closing the socket.
+func CreateSyslogLogger(args *SyslogHandlerArgs) (logger *slog.Logger, err error) {
+	var h *SyslogHandler
+
+	h, err = NewSyslogHandler(args)
+	if err != nil {
+		goto end
+	}
+	if args != nil && args.RegisterFinalizer {
+		RegisterFinalizerFunc(func(context.Context) error {
+			return h.Close()
+		})
+	}
+	logger = slog.New(h)
+end:
+	return logger, err
+}
+
+// SyslogSeverityFor maps level to a syslog severity: LevelFatal and above to
+// critical, errors to error, warnings to warning, info to info and anything
+// below to debug.
+func SyslogSeverityFor(level slog.Level) (s SyslogSeverity) {
+	switch {
+	case level >= LevelFatal:
+		s = SeverityCritical
+	case level >= slog.LevelError:
+		s = SeverityError
+	case level >= slog.LevelWarn:
+		s = SeverityWarning
+	case level >= slog.LevelInfo:
+		s = SeverityInfo
+	default:
+		s = SeverityDebug
+	}
+	return s
+}
+
+func (h *SyslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.args.Level.Level()
+}
+
+func (h *SyslogHandler) Handle(_ context.Context, r slog.Record) error {
+	return h.conn.write(h.format(r))
+}
+
+// Close closes the socket; later writes fail.
+func (h *SyslogHandler) Close() error {
+	return h.conn.close()
+}
+
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.params = h.params[:len(h.params):len(h.params)]
+	for _, a := range attrs {
+		h2.params = appendFlatAttrs(h2.params, h.groups, a)
+	}
+	return &h2
+}
+
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// format renders r as a syslog message in the configured format.
+func (h *SyslogHandler) format(r slog.Record) []byte {
+	var sb strings.Builder
+
+	params := h.params[:len(h.params):len(h.params)]
+	r.Attrs(func(a slog.Attr) bool {
+		params = appendFlatAttrs(params, h.groups, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	pri := int(h.args.Facility)*8 + int(SyslogSeverityFor(r.Level))
+
+	if h.args.Format == RFC3164 {
+		fmt.Fprintf(&sb, "<%d>%s %s %s[%s]: %s",
+			pri,
+			t.Format(time.Stamp),
+			syslogHeaderField(h.args.Hostname),
+			syslogHeaderField(h.args.AppName),
+			h.pid,
+			r.Message,
+		)
+		for _, p := range params {
+			sb.WriteByte(' ')
+			sb.WriteString(p.key)
+			sb.WriteByte('=')
+			if needsQuoting(p.value) {
+				sb.WriteString(strconv.Quote(p.value))
+				continue
+			}
+			sb.WriteString(p.value)
+		}
+		goto end
+	}
+
+	fmt.Fprintf(&sb, "<%d>1 %s %s %s %s - ",
+		pri,
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(h.args.Hostname),
+		syslogHeaderField(h.args.AppName),
+		h.pid,
+	)
+	if len(params) == 0 {
+		sb.WriteByte('-')
+	} else {
+		sb.WriteByte('[')
+		sb.WriteString(h.args.SDID)
+		for _, p := range params {
+			sb.WriteByte(' ')
+			sb.WriteString(flatAttrName(p.key))
+			sb.WriteString(`="`)
+			sb.WriteString(flatAttrValue(p.value))
+			sb.WriteByte('"')
+		}
+		sb.WriteByte(']')
+	}
+	if r.Message != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(r.Message)
+	}
+end:
+	return []byte(sb.String())
+}
+
+// syslogHeaderField returns s with characters not allowed in header fields
+// replaced, or "-" when s is empty.
+func syslogHeaderField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// flatAttrName returns key as a valid RFC 5424 PARAM-NAME: at most 32
+// printable ASCII characters other than '=', ' ', ']' and '"'.
+func flatAttrName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r <= ' ' || r > '~', r == '=', r == ']', r == '"':
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// flatAttrValue escapes '"', '\' and ']' in s as required for an RFC 5424
+// PARAM-VALUE.
+func flatAttrValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
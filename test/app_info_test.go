@@ -0,0 +1,148 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+// jsonLines decodes each line of JSON output.
+func jsonLines(t *testing.T, data []byte) (recs []map[string]any) {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec map[string]any
+		err := json.Unmarshal([]byte(line), &rec)
+		if err != nil {
+			t.Fatalf("json.Unmarshal() failed for %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestAppInfoAttrs(t *testing.T) {
+	attrs := logutil.AppInfoAttrs(testAppInfo())
+	got := make(map[string]slog.Value)
+	for _, a := range attrs {
+		got[a.Key] = a.Value
+	}
+	if got[logutil.AppNameKey].String() != "myapp" || got[logutil.AppVersionKey].String() != "1.2.3" {
+		t.Errorf("unexpected app attributes: %v", attrs)
+	}
+	if got[logutil.PIDKey].Int64() != int64(os.Getpid()) {
+		t.Errorf("expected pid %d, got %v", os.Getpid(), got[logutil.PIDKey])
+	}
+	if got[logutil.RunIDKey].String() != logutil.RunID() || len(logutil.RunID()) != 16 {
+		t.Errorf("expected run_id %q of 16 hex digits, got %v", logutil.RunID(), got[logutil.RunIDKey])
+	}
+	for _, key := range []string{logutil.GoVersionKey, logutil.HostKey} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing %s attribute", key)
+		}
+	}
+
+	// Without AppInfo the executable's name is used.
+	attrs = logutil.AppInfoAttrs(nil)
+	if attrs[0].Key != logutil.AppNameKey || attrs[0].Value.String() != filepath.Base(os.Args[0]) {
+		t.Errorf("unexpected fallback name attribute %v", attrs[0])
+	}
+}
+
+func TestCreateLogger_AppInfoModes(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logutil.CreateLogger(&logutil.LoggerArgs{
+		Writer:      &buf,
+		Format:      logutil.JSONFormat,
+		Level:       slog.LevelInfo,
+		AppInfo:     testAppInfo(),
+		AppInfoMode: logutil.AppInfoPerRecord,
+	})
+	logger.Info("first")
+	logger.Info("second")
+	for _, rec := range jsonLines(t, buf.Bytes()) {
+		if rec[logutil.AppNameKey] != "myapp" || rec[logutil.RunIDKey] != logutil.RunID() {
+			t.Errorf("record lacks app attributes: %v", rec)
+		}
+	}
+
+	buf.Reset()
+	logger = logutil.CreateLogger(&logutil.LoggerArgs{
+		Writer:      &buf,
+		Format:      logutil.JSONFormat,
+		Level:       slog.LevelError,
+		AppInfo:     testAppInfo(),
+		AppInfoMode: logutil.AppInfoHeader,
+	})
+	logger.Error("only record")
+	recs := jsonLines(t, buf.Bytes())
+	if len(recs) != 2 {
+		t.Fatalf("expected header and record, got %v", recs)
+	}
+	if recs[0]["msg"] != logutil.AppInfoHeaderMessage || recs[0][logutil.AppVersionKey] != "1.2.3" {
+		t.Errorf("unexpected header %v", recs[0])
+	}
+	if _, ok := recs[1][logutil.AppNameKey]; ok {
+		t.Errorf("record should not repeat app attributes: %v", recs[1])
+	}
+}
+
+func TestCreateJSONFileLoggerWithArgs_HeaderOnlyInNewFile(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "app.log"))
+	args := &logutil.JSONFileLoggerArgs{
+		AppInfo:     testAppInfo(),
+		AppInfoMode: logutil.AppInfoHeader,
+	}
+	for _, msg := range []string{"first run", "second run"} {
+		logger, err := logutil.CreateJSONFileLoggerWithArgs(file, args)
+		if err != nil {
+			t.Fatalf("CreateJSONFileLoggerWithArgs() failed: %v", err)
+		}
+		logger.Warn(msg)
+		err = logger.Handler().(*logutil.JSONHandler).Close()
+		if err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+	}
+	data, err := file.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	var msgs []string
+	for _, rec := range jsonLines(t, data) {
+		msgs = append(msgs, rec["msg"].(string))
+	}
+	want := logutil.AppInfoHeaderMessage + ",first run,second run"
+	if got := strings.Join(msgs, ","); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestInitializerRegistry_AppInfoPerRecord(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	r := logutil.NewInitializerRegistry()
+	r.Register("app-info", func(args logutil.InitializerArgs) error {
+		args.Logger.Info("initializing")
+		return nil
+	})
+	err := r.Call(logutil.InitializerArgs{
+		AppInfo:     testAppInfo(),
+		AppInfoMode: logutil.AppInfoPerRecord,
+		Logger:      rec.Logger(),
+	})
+	if err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	rec.RequireLogged(t, slog.LevelInfo, "initializing",
+		logutil.AppNameKey, "myapp",
+		logutil.RunIDKey, logutil.RunID(),
+	)
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/mikeschinkel/go-cliutil"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// recordingWriter is a cliutil.Writer that records which method output was
+// routed through.
+type recordingWriter struct {
+	out   *bytes.Buffer
+	quiet bool
+	level int
+}
+
+func (w *recordingWriter) Printf(format string, args ...any) {
+	if w.quiet {
+		return
+	}
+	_, _ = fmt.Fprintf(w.out, "[printf v%d] "+format, append([]any{w.level}, args...)...)
+}
+
+func (w *recordingWriter) Errorf(format string, args ...any) {
+	_, _ = fmt.Fprintf(w.out, "[errorf] "+format, args...)
+}
+
+func (w *recordingWriter) Loud() cliutil.Writer { return w }
+func (w *recordingWriter) V2() cliutil.Writer {
+	return &recordingWriter{out: w.out, quiet: w.quiet, level: 2}
+}
+func (w *recordingWriter) V3() cliutil.Writer {
+	return &recordingWriter{out: w.out, quiet: w.quiet, level: 3}
+}
+func (w *recordingWriter) Writer() io.Writer    { return w.out }
+func (w *recordingWriter) ErrWriter() io.Writer { return w.out }
+
+func TestCLIWriterHandler_RoutesByLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := &recordingWriter{out: &buf, level: 1}
+	logger := slog.New(logutil.NewCLIWriterHandler(w, &logutil.CLIWriterHandlerArgs{
+		Level: slog.LevelDebug - 4,
+		Color: logutil.NeverColor,
+	}))
+	logger.Error("e", "k", "v")
+	logger.Info("i")
+	logger.Debug("d")
+	logger.Log(t.Context(), slog.LevelDebug-4, "t")
+
+	want := "[errorf] ERR e k=v\n[printf v1] INF i\n[printf v2] DBG d\n[printf v3] DBG-4 t\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output\n got: %q\nwant: %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	w.quiet = true
+	logger.Info("i")
+	logger.Warn("w")
+	if buf.String() != "[errorf] WRN w\n" {
+		t.Errorf("expected only the warning when quiet, got %q", buf.String())
+	}
+}
+
+func TestLevelForVerbosity(t *testing.T) {
+	tests := []struct {
+		quiet     bool
+		verbosity cliutil.Verbosity
+		want      slog.Level
+	}{
+		{quiet: true, verbosity: cliutil.HighVerbosity, want: slog.LevelWarn},
+		{verbosity: cliutil.LowVerbosity, want: slog.LevelInfo},
+		{verbosity: cliutil.MediumVerbosity, want: slog.LevelDebug},
+		{verbosity: cliutil.HighVerbosity, want: slog.LevelDebug - 4},
+	}
+	for _, tt := range tests {
+		got := logutil.LevelForVerbosity(tt.quiet, tt.verbosity)
+		if got != tt.want {
+			t.Errorf("LevelForVerbosity(%v, %d) = %v; want %v", tt.quiet, tt.verbosity, got, tt.want)
+		}
+	}
+}
+
+func TestSetLevelFromOptions(t *testing.T) {
+	prev := logutil.GetLevel()
+	t.Cleanup(func() { logutil.SetLevel(prev) })
+
+	verbosity := int(cliutil.MediumVerbosity)
+	opts, err := cliutil.NewCLIOptions(cliutil.CLIOptionsArgs{Verbosity: &verbosity})
+	if err != nil {
+		t.Fatalf("NewCLIOptions() failed: %v", err)
+	}
+	logutil.SetLevelFromOptions(opts)
+	if logutil.GetLevel() != slog.LevelDebug {
+		t.Errorf("expected debug level, got %v", logutil.GetLevel())
+	}
+}
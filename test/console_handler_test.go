@@ -0,0 +1,65 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestConsoleHandler_PlainOutput(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := logutil.NewConsoleHandler(&buf, &logutil.ConsoleHandlerArgs{
+		Level:        slog.LevelDebug,
+		MessageWidth: 10,
+	})
+	logger := slog.New(h).WithGroup("req").With("id", 7)
+
+	r := slog.NewRecord(time.Date(2025, 1, 2, 3, 4, 5, 6_000_000, time.UTC), slog.LevelWarn+2, "hello", 0)
+	r.AddAttrs(slog.String("path", "/a b"), slog.Group("user", slog.String("name", "bob")))
+	err := logger.Handler().Handle(context.Background(), r)
+	if err != nil {
+		t.Fatalf("Handle() failed: %v", err)
+	}
+
+	want := `03:04:05.006 WRN+2 hello      req.id=7 req.path="/a b" req.user.name=bob` + "\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output\n got: %q\nwant: %q", buf.String(), want)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("expected no color escapes when not writing to a terminal")
+	}
+}
+
+func TestConsoleHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(logutil.NewConsoleHandler(&buf, &logutil.ConsoleHandlerArgs{
+		Color:      logutil.AlwaysColor,
+		TimeFormat: "-",
+	}))
+	logger.Error("failed", "k", "v")
+
+	if !strings.HasPrefix(buf.String(), "\x1b[1m\x1b[31mERR\x1b[0m failed") {
+		t.Errorf("expected colored level prefix, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "\x1b[2m k=v\x1b[0m") {
+		t.Errorf("expected dimmed attributes, got %q", buf.String())
+	}
+}
+
+func TestConsoleHandler_ForceColor(t *testing.T) {
+	var buf bytes.Buffer
+
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("FORCE_COLOR", "1")
+	slog.New(logutil.NewConsoleHandler(&buf, nil)).Info("hello")
+	if !strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("expected FORCE_COLOR to enable color, got %q", buf.String())
+	}
+}
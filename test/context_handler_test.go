@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestContextHandler_AppendsContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	var m map[string]any
+
+	logger := logutil.WithContext(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	ctx := logutil.ContextWithAttrs(context.Background(), slog.String("request_id", "r-1"))
+	ctx = logutil.ContextWithArgs(ctx, "user_id", 42)
+	logger.InfoContext(ctx, "hello", "extra", true)
+
+	err := json.Unmarshal(buf.Bytes(), &m)
+	if err != nil {
+		t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+	}
+	if m["request_id"] != "r-1" {
+		t.Errorf("expected request_id %q, got %v", "r-1", m["request_id"])
+	}
+	if m["user_id"] != float64(42) {
+		t.Errorf("expected user_id %d, got %v", 42, m["user_id"])
+	}
+	if m["extra"] != true {
+		t.Errorf("expected extra true, got %v", m["extra"])
+	}
+}
+
+func TestContextHandler_AppendsContextAttrsToTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logutil.WithContext(logutil.CreateLogger(&logutil.LoggerArgs{
+		Writer: &buf,
+		Format: logutil.TextFormat,
+		Level:  slog.LevelInfo,
+	}))
+
+	ctx := logutil.ContextWithAttrs(context.Background(), slog.String("request_id", "r-1"))
+	ctx = logutil.ContextWithArgs(ctx, "user_id", 42)
+	logger.InfoContext(ctx, "hello", "extra", true)
+
+	out := buf.String()
+	if !strings.Contains(out, "msg=hello request_id=r-1 user_id=42 extra=true") {
+		t.Errorf("expected context attrs in text output, got %q", out)
+	}
+}
+
+func TestContextHandler_NoContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logutil.WithContext(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logger.InfoContext(context.Background(), "hello")
+
+	if bytes.Contains(buf.Bytes(), []byte("request_id")) {
+		t.Errorf("expected no context attrs, got %s", buf.String())
+	}
+}
+
+func TestContextHandler_GetJSONFilepath(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "logs", "test.log"))
+
+	logger, err := logutil.CreateJSONFileLogger(file)
+	if err != nil {
+		t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+	}
+	logger = logutil.WithContext(logger).With("component", "test")
+
+	got := logutil.GetJSONFilepath(logger)
+	if got != file {
+		t.Errorf("expected filepath %q, got %q", file, got)
+	}
+}
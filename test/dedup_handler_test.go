@@ -0,0 +1,109 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+func TestDedupHandler_CollapsesConsecutiveDuplicates(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rec := logutiltest.NewRecorder(nil)
+	logger := slog.New(logutil.NewDedupHandler(rec, &logutil.DedupHandlerArgs{
+		Window: time.Minute,
+		Now:    clock.Now,
+	})).With("conn", 1)
+
+	for range 58 {
+		logger.Warn("connection reset", "retry", true)
+		clock.Advance(time.Millisecond)
+	}
+	logger.Warn("connection reset", "retry", false)
+
+	want := []string{"connection reset", "message repeated 57 times", "connection reset"}
+	got := messages(rec)
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	rec.RequireLogged(t, slog.LevelWarn, "message repeated 57 times",
+		"conn", 1,
+		"repeated_msg", "connection reset",
+		"repeated", 57,
+	)
+}
+
+func TestDedupHandler_WindowAndFlush(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rec := logutiltest.NewRecorder(nil)
+	h := logutil.NewDedupHandler(rec, &logutil.DedupHandlerArgs{
+		Window: time.Second,
+		Now:    clock.Now,
+	})
+	logger := slog.New(h)
+
+	logger.Info("tick")
+	logger.Info("tick")
+	clock.Advance(2 * time.Second)
+	logger.Info("tick")
+	logger.Info("tick")
+	logger.Info("tick")
+	err := h.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	want := []string{"tick", "message repeated 1 time", "tick", "message repeated 2 times"}
+	got := messages(rec)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDedupHandler_ReportsRepeatsWhenWindowEnds(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	h := logutil.NewDedupHandler(rec, &logutil.DedupHandlerArgs{
+		Window: 20 * time.Millisecond,
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h)
+	for range 3 {
+		logger.Info("tick")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(messages(rec)) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("repeat record was not emitted when the window ended")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	want := []string{"tick", "message repeated 2 times"}
+	if got := messages(rec); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDedupHandler_DistinguishesValueKinds(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	logger := slog.New(logutil.NewDedupHandler(rec, nil))
+
+	logger.Info("value", "v", 1)
+	logger.Info("value", "v", "1")
+
+	want := []string{"value", "value"}
+	if got := messages(rec); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func messages(rec *logutiltest.Recorder) (msgs []string) {
+	for _, r := range rec.Records() {
+		msgs = append(msgs, r.Message)
+	}
+	return msgs
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestInitializerRegistry_FinalizersRunInReverse(t *testing.T) {
+	var calls []string
+
+	finalize := func(name string) logutil.FinalizerFunc {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	noop := func(logutil.InitializerArgs) error { return nil }
+	failed := errors.New("failed")
+
+	r := logutil.NewInitializerRegistry()
+	r.Register("http", noop, logutil.After("db"), logutil.Finalizer(finalize("http")))
+	r.Register("db", noop, logutil.Finalizer(finalize("db")))
+	r.Register("broken", func(logutil.InitializerArgs) error { return failed }, logutil.Finalizer(finalize("broken")))
+
+	err := r.Call(logutil.InitializerArgs{})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected initializer error, got %v", err)
+	}
+	r.RegisterFinalizerFunc(finalize("standalone"))
+
+	err = r.CallFinalizers(context.Background())
+	if err != nil {
+		t.Fatalf("CallFinalizers() failed: %v", err)
+	}
+	want := []string{"standalone", "http", "db"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected finalizer order %v, got %v", want, calls)
+	}
+
+	err = r.CallFinalizers(context.Background())
+	if err != nil || len(calls) != len(want) {
+		t.Errorf("expected finalizers to run only once, got %v (%v)", calls, err)
+	}
+}
+
+func TestInitializerRegistry_FinalizerDeadline(t *testing.T) {
+	var ran bool
+
+	r := logutil.NewInitializerRegistry()
+	r.RegisterFinalizerFunc(func(context.Context) error {
+		ran = true
+		return nil
+	})
+	r.RegisterFinalizerFunc(func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.CallFinalizers(ctx)
+	if !errors.Is(err, logutil.ErrFinalizerTimeout) {
+		t.Errorf("expected ErrFinalizerTimeout, got %v", err)
+	}
+	if !errors.Is(err, logutil.ErrFinalizerSkipped) {
+		t.Errorf("expected ErrFinalizerSkipped, got %v", err)
+	}
+	if ran {
+		t.Errorf("expected finalizer after deadline to be skipped")
+	}
+	if !strings.Contains(err.Error(), "finalizer_test.go:") {
+		t.Errorf("expected finalizer registration site in error, got %v", err)
+	}
+}
+
+func TestJSONHandler_Close(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "test.log"))
+
+	logger, err := logutil.CreateJSONFileLogger(file)
+	if err != nil {
+		t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+	}
+	logger.Info("before close")
+	h := logger.With("k", "v").Handler().(*logutil.JSONHandler)
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	err = logger.Handler().(*logutil.JSONHandler).Close()
+	if err != nil {
+		t.Errorf("expected second Close() to succeed, got %v", err)
+	}
+}
+
+func TestCreateJSONFileLoggerWithArgs_RegisterFinalizer(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "after finalizers", 0)
+
+	unregistered, err := logutil.CreateJSONFileLogger(dt.Filepath(filepath.Join(dir, "unregistered.log")))
+	if err != nil {
+		t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = unregistered.Handler().(*logutil.JSONHandler).Close() })
+	registered, err := logutil.CreateJSONFileLoggerWithArgs(
+		dt.Filepath(filepath.Join(dir, "registered.log")),
+		&logutil.JSONFileLoggerArgs{RegisterFinalizer: true},
+	)
+	if err != nil {
+		t.Fatalf("CreateJSONFileLoggerWithArgs() failed: %v", err)
+	}
+	err = logutil.CallFinalizerFuncs(ctx)
+	if err != nil {
+		t.Fatalf("CallFinalizerFuncs() failed: %v", err)
+	}
+	err = registered.Handler().Handle(ctx, r)
+	if err == nil {
+		t.Errorf("expected registered file to be closed by CallFinalizerFuncs()")
+	}
+	err = unregistered.Handler().Handle(ctx, r)
+	if err != nil {
+		t.Errorf("expected unregistered file to stay open, got %v", err)
+	}
+}
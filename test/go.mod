@@ -5,12 +5,10 @@ go 1.25.3
 replace github.com/mikeschinkel/go-logutil => ..
 
 require (
+	github.com/mikeschinkel/go-cliutil v0.2.1
 	github.com/mikeschinkel/go-dt v0.3.3
+	github.com/mikeschinkel/go-dt/appinfo v0.2.1
 	github.com/mikeschinkel/go-logutil v0.2.1
 )
 
-require (
-	github.com/mikeschinkel/go-cliutil v0.2.1 // indirect
-	github.com/mikeschinkel/go-dt/appinfo v0.2.1 // indirect
-	github.com/mikeschinkel/go-dt/dtx v0.2.1 // indirect
-)
+require github.com/mikeschinkel/go-dt/dtx v0.2.1 // indirect
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+var (
+	textTimeRE = regexp.MustCompile(`^time=\S+ `)
+	jsonTimeRE = regexp.MustCompile(`"time":"[^"]*",`)
+)
+
+type goldenCase struct {
+	name string
+	v    any
+}
+
+type specialChars struct {
+	Spaces  string         `json:"spaces"`
+	Quotes  string         `json:"quotes"`
+	Newline string         `json:"newline"`
+	Unicode string         `json:"unicode"`
+	Empty   string         `json:"empty"`
+	Tags    []string       `json:"tags"`
+	Counts  map[string]int `json:"counts"`
+}
+
+func goldenCases() []goldenCase {
+	now := time.Date(2025, 11, 22, 12, 0, 0, 123456789, time.UTC)
+	return []goldenCase{
+		{name: "struct", v: testStruct{
+			unexported: "skipped",
+			ID:         123,
+			Name:       "search-1",
+			When:       now,
+			Err:        errors.New("boom"),
+			Type:       EntryTypeSearch,
+			Hidden:     "skipped",
+			Nested:     nested{ID: 1, When: now},
+			Slice:      []string{"a", "b"},
+			PtrNested:  &nested{ID: 2, When: now},
+		}},
+		{name: "omitempty", v: testStruct{ID: 0}},
+		{name: "pointer", v: &nested{ID: 3, When: now}},
+		{name: "non_struct", v: "hello world"},
+		{name: "special_chars", v: specialChars{
+			Spaces:  "with spaces",
+			Quotes:  `say "hi"`,
+			Newline: "line1\nline2",
+			Unicode: "你好世界",
+			Tags:    []string{"x", "y z"},
+			Counts:  map[string]int{"a": 1, "b": 2},
+		}},
+	}
+}
+
+func TestLogArgs_GoldenText(t *testing.T) {
+	logutiltest.UpdateGolden = *update
+	for _, tc := range goldenCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			logger := logutil.CreateLogger(&logutil.LoggerArgs{
+				Writer: &buf,
+				Format: logutil.TextFormat,
+			})
+			logger.Info("log args", logutil.LogArgs(tc.v)...)
+
+			got := textTimeRE.ReplaceAll(buf.Bytes(), nil)
+			logutiltest.RequireGolden(t, goldenPath(tc.name, "text"), got)
+		})
+	}
+}
+
+func TestLogArgs_GoldenJSON(t *testing.T) {
+	logutiltest.UpdateGolden = *update
+	for _, tc := range goldenCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			file := dt.Filepath(filepath.Join(t.TempDir(), "golden.log"))
+
+			logger, err := logutil.CreateJSONFileLogger(file)
+			if err != nil {
+				t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+			}
+			logger.Info("log args", logutil.LogArgs(tc.v)...)
+			err = logger.Handler().(*logutil.JSONHandler).Close()
+			if err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+			out, err := os.ReadFile(string(file))
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+
+			got := jsonTimeRE.ReplaceAll(out, nil)
+			logutiltest.RequireGolden(t, goldenPath(tc.name, "json"), got)
+		})
+	}
+}
+
+func goldenPath(name, format string) string {
+	return filepath.Join("testdata", "log_args_"+name+"."+format+".golden")
+}
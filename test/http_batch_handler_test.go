@@ -0,0 +1,278 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// ndjsonMessages returns the msg of each record in an NDJSON body.
+func ndjsonMessages(t *testing.T, body []byte) (msgs []string) {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		var rec map[string]any
+		err := json.Unmarshal([]byte(line), &rec)
+		if err != nil {
+			t.Fatalf("json.Unmarshal() failed for %q: %v", line, err)
+		}
+		msgs = append(msgs, rec["msg"].(string))
+	}
+	return msgs
+}
+
+func TestHTTPBatchHandler_BatchesByCount(t *testing.T) {
+	c := newCollector(t)
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:       c.URL,
+		Headers:   map[string]string{"X-Api-Key": "secret"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	logger := slog.New(h).With("svc", "api")
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		logger.Info(msg)
+	}
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	reqs, bodies := c.received()
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(reqs))
+	}
+	var msgs []string
+	for i, req := range reqs {
+		if req.Header.Get("Content-Encoding") != "gzip" || req.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("unexpected headers: %v", req.Header)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		batch := ndjsonMessages(t, gunzip(t, bodies[i]))
+		if len(batch) > 2 {
+			t.Errorf("batch of %d exceeds BatchSize", len(batch))
+		}
+		msgs = append(msgs, batch...)
+	}
+	if strings.Join(msgs, "") != "abcde" {
+		t.Errorf("got messages %v", msgs)
+	}
+}
+
+func TestHTTPBatchHandler_BatchesByBytesAsJSONArray(t *testing.T) {
+	c := newCollector(t)
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:         c.URL,
+		Format:      logutil.JSONArrayBody,
+		DisableGzip: true,
+		BatchBytes:  200,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	logger := slog.New(h)
+	for range 4 {
+		logger.Info("a message of moderate length", "padding", strings.Repeat("x", 40))
+	}
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	reqs, bodies := c.received()
+	if len(reqs) < 2 {
+		t.Fatalf("expected BatchBytes to split the records, got %d request(s)", len(reqs))
+	}
+	total := 0
+	for _, body := range bodies {
+		var recs []map[string]any
+		err = json.Unmarshal(body, &recs)
+		if err != nil {
+			t.Fatalf("json.Unmarshal() failed for %s: %v", body, err)
+		}
+		if len(body) > 200 {
+			t.Errorf("body of %d bytes exceeds BatchBytes", len(body))
+		}
+		total += len(recs)
+	}
+	if total != 4 {
+		t.Errorf("expected 4 records, got %d", total)
+	}
+}
+
+func TestHTTPBatchHandler_SendsOnInterval(t *testing.T) {
+	c := newCollector(t)
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:           c.URL,
+		BatchInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	slog.New(h).Info("tick")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if reqs, _ := c.received(); len(reqs) > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("partial batch was not sent after BatchInterval")
+}
+
+func TestHTTPBatchHandler_SpillsAndReplays(t *testing.T) {
+	c := newCollector(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	spill := dt.Filepath(filepath.Join(t.TempDir(), "queue", "spill.jsonl"))
+	args := &logutil.HTTPBatchHandlerArgs{
+		URL:         c.URL,
+		DisableGzip: true,
+		SpillFile:   spill,
+		Backoff:     logutil.BackoffArgs{MaxAttempts: 2, Initial: time.Millisecond},
+	}
+	h, err := logutil.NewHTTPBatchHandler(args)
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	slog.New(h).Info("while offline")
+	err = h.Flush(context.Background())
+	if !errors.Is(err, logutil.ErrExportFailed) {
+		t.Fatalf("expected ErrExportFailed, got %v", err)
+	}
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	data, err := spill.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if got := ndjsonMessages(t, data); len(got) != 1 || got[0] != "while offline" {
+		t.Fatalf("unexpected spilled records %v", got)
+	}
+
+	// A later handler, e.g. in the next run, replays after its first success.
+	h, err = logutil.NewHTTPBatchHandler(args)
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	slog.New(h).Info("back online")
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	_, bodies := c.received()
+	if len(bodies) != 4 {
+		t.Fatalf("expected 2 failed attempts, 1 send and 1 replay, got %d requests", len(bodies))
+	}
+	if got := ndjsonMessages(t, bodies[2]); got[0] != "back online" {
+		t.Errorf("unexpected send %v", got)
+	}
+	if got := ndjsonMessages(t, bodies[3]); got[0] != "while offline" {
+		t.Errorf("unexpected replay %v", got)
+	}
+	if exists, _ := spill.Exists(); exists {
+		t.Error("spill file should be removed once replayed")
+	}
+}
+
+func TestNewHTTPBatchHandler_MissingURL(t *testing.T) {
+	_, err := logutil.NewHTTPBatchHandler(nil)
+	if !errors.Is(err, logutil.ErrMissingEndpoint) {
+		t.Fatalf("expected ErrMissingEndpoint, got %v", err)
+	}
+}
+
+func TestHTTPBatchHandler_ReplayFailureKeepsSentBatchOutOfSpill(t *testing.T) {
+	c := newCollector(t, http.StatusOK, http.StatusServiceUnavailable)
+	spill := dt.Filepath(filepath.Join(t.TempDir(), "spill.jsonl"))
+	err := spill.WriteFile([]byte(`{"msg":"offline"}`+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	var errs []error
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:         c.URL,
+		DisableGzip: true,
+		SpillFile:   spill,
+		Backoff:     logutil.BackoffArgs{MaxAttempts: 1},
+		OnError:     func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	slog.New(h).Info("live")
+	err = h.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], logutil.ErrExportFailed) {
+		t.Errorf("expected the replay failure passed to OnError, got %v", errs)
+	}
+	data, err := spill.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if got := ndjsonMessages(t, data); len(got) != 1 || got[0] != "offline" {
+		t.Fatalf("expected only the unreplayed record spilled, got %v", got)
+	}
+}
+
+func TestHTTPBatchHandler_KeepsReplayWhenRespillFails(t *testing.T) {
+	c := newCollector(t, http.StatusOK, http.StatusServiceUnavailable)
+	dir := t.TempDir()
+	spill := dt.Filepath(filepath.Join(dir, "spill.jsonl"))
+	replay := dt.Filepath(string(spill) + ".replay")
+	// A previous replay was interrupted, and the spill file cannot be written.
+	err := replay.WriteFile([]byte(`{"msg":"offline"}`+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	err = os.Mkdir(string(spill), 0755)
+	if err != nil {
+		t.Fatalf("os.Mkdir() failed: %v", err)
+	}
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:         c.URL,
+		DisableGzip: true,
+		SpillFile:   spill,
+		Backoff:     logutil.BackoffArgs{MaxAttempts: 1},
+		OnError:     func(error) {},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	slog.New(h).Info("live")
+	err = h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if reqs, _ := c.received(); len(reqs) != 2 {
+		t.Fatalf("expected a send and a failed replay, got %d requests", len(reqs))
+	}
+	data, err := replay.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if got := ndjsonMessages(t, data); len(got) != 1 || got[0] != "offline" {
+		t.Fatalf("expected the unsent record kept for replay, got %v", got)
+	}
+}
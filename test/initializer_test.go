@@ -0,0 +1,171 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestInitializerRegistry_DependencyOrder(t *testing.T) {
+	var calls []string
+
+	record := func(name string) logutil.InitializerFunc {
+		return func(logutil.InitializerArgs) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	r := logutil.NewInitializerRegistry()
+	r.Register("http", record("http"), logutil.After("config", "db"))
+	r.Register("db", record("db"), logutil.After("config"))
+	r.RegisterFunc(record("unnamed"))
+	r.Register("config", record("config"))
+
+	err := r.Call(logutil.InitializerArgs{})
+	if err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	want := []string{"unnamed", "config", "db", "http"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected call order %v, got %v", want, calls)
+	}
+
+	infos, err := r.Initializers()
+	if err != nil {
+		t.Fatalf("Initializers() failed: %v", err)
+	}
+	if infos[0].Name != "" || !strings.Contains(infos[0].Site, "initializer_test.go:") {
+		t.Errorf("expected unnamed initializer with registration site, got %+v", infos[0])
+	}
+	if infos[3].Name != "http" || !slices.Equal(infos[3].After, []string{"config", "db"}) {
+		t.Errorf("expected http initializer last with its dependencies, got %+v", infos[3])
+	}
+}
+
+func TestInitializerRegistry_Cycle(t *testing.T) {
+	noop := func(logutil.InitializerArgs) error { return nil }
+
+	r := logutil.NewInitializerRegistry()
+	r.Register("a", noop, logutil.After("c"))
+	r.Register("b", noop, logutil.After("a"))
+	r.Register("c", noop, logutil.After("b"))
+
+	err := r.Call(logutil.InitializerArgs{})
+	if !errors.Is(err, logutil.ErrInitializerCycle) {
+		t.Fatalf("expected ErrInitializerCycle, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "a -> c -> b -> a") {
+		t.Errorf("expected cycle path in error, got %v", err)
+	}
+}
+
+func TestInitializerRegistry_InvalidRegistrations(t *testing.T) {
+	noop := func(logutil.InitializerArgs) error { return nil }
+
+	r := logutil.NewInitializerRegistry()
+	r.Register("a", noop, logutil.After("missing"))
+	_, err := r.Initializers()
+	if !errors.Is(err, logutil.ErrUnknownInitializer) {
+		t.Errorf("expected ErrUnknownInitializer, got %v", err)
+	}
+
+	r = logutil.NewInitializerRegistry()
+	r.Register("a", noop)
+	r.Register("a", noop)
+	_, err = r.Initializers()
+	if !errors.Is(err, logutil.ErrDuplicateInitializer) {
+		t.Errorf("expected ErrDuplicateInitializer, got %v", err)
+	}
+}
+
+func TestInitializers_IncludesLogutil(t *testing.T) {
+	infos, err := logutil.Initializers()
+	if err != nil {
+		t.Fatalf("Initializers() failed: %v", err)
+	}
+	found := slices.ContainsFunc(infos, func(info logutil.InitializerInfo) bool {
+		return info.Name == "logutil"
+	})
+	if !found {
+		t.Errorf("expected logutil initializer to be registered, got %+v", infos)
+	}
+}
+
+func TestInitializerRegistry_RecoversPanics(t *testing.T) {
+	var called bool
+
+	r := logutil.NewInitializerRegistry()
+	r.Register("boom", func(logutil.InitializerArgs) error { panic("kaboom") })
+	r.Register("next", func(logutil.InitializerArgs) error {
+		called = true
+		return nil
+	})
+
+	err := r.Call(logutil.InitializerArgs{})
+	if !errors.Is(err, logutil.ErrInitializerPanicked) {
+		t.Fatalf("expected ErrInitializerPanicked, got %v", err)
+	}
+	for _, want := range []string{"boom", "kaboom", "initializer_test.go:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error, got %v", want, err)
+		}
+	}
+	if !called {
+		t.Errorf("expected initializers after a panic to still be called")
+	}
+}
+
+func TestInitializerRegistry_ContextAndTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	var calls []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan error, 1)
+	r := logutil.NewInitializerRegistry()
+	r.Register("slow", func(args logutil.InitializerArgs) error {
+		select {
+		case <-args.Context.Done():
+			stopped <- args.Context.Err()
+		case <-time.After(time.Second):
+			stopped <- nil
+		}
+		return nil
+	}, logutil.Timeout(10*time.Millisecond))
+	r.Register("cancel", func(logutil.InitializerArgs) error {
+		calls = append(calls, "cancel")
+		cancel()
+		return nil
+	}, logutil.After("slow"))
+	r.Register("skipped", func(logutil.InitializerArgs) error {
+		calls = append(calls, "skipped")
+		return nil
+	}, logutil.After("cancel"))
+
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	err := r.CallContext(ctx, logutil.InitializerArgs{Logger: logger})
+	if !errors.Is(err, logutil.ErrInitializerTimeout) {
+		t.Errorf("expected ErrInitializerTimeout, got %v", err)
+	}
+	if !errors.Is(err, logutil.ErrInitializerSkipped) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ErrInitializerSkipped caused by cancellation, got %v", err)
+	}
+	if !slices.Equal(calls, []string{"cancel"}) {
+		t.Errorf("expected only the cancel initializer to complete, got %v", calls)
+	}
+	if !strings.Contains(buf.String(), "initializer=cancel duration=") {
+		t.Errorf("expected initializer duration to be logged, got %s", buf.String())
+	}
+	err = <-stopped
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timed-out initializer's context to be done, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// parseJournalFields decodes a native protocol datagram into its fields.
+func parseJournalFields(t *testing.T, msg string) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for msg != "" {
+		nl := strings.IndexByte(msg, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field: %q", msg)
+		}
+		line := msg[:nl]
+		if name, value, ok := strings.Cut(line, "="); ok {
+			fields[name] = value
+			msg = msg[nl+1:]
+			continue
+		}
+		rest := msg[nl+1:]
+		n := int(binary.LittleEndian.Uint64([]byte(rest[:8])))
+		fields[line] = rest[8 : 8+n]
+		if rest[8+n] != '\n' {
+			t.Fatalf("binary field %s not newline-terminated", line)
+		}
+		msg = rest[8+n+1:]
+	}
+	return fields
+}
+
+func TestJournalHandler_Fields(t *testing.T) {
+	socket, next := listenUnixgram(t)
+	h, err := logutil.NewJournalHandler(&logutil.JournalHandlerArgs{
+		Socket:           socket,
+		SyslogIdentifier: "myapp",
+	})
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h).With("request-id", "r1").WithGroup("db")
+
+	logger.Error("query failed", "table", "users", "query", "SELECT *\nFROM users")
+	fields := parseJournalFields(t, next())
+
+	want := map[string]string{
+		"MESSAGE":           "query failed",
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": "myapp",
+		"REQUEST_ID":        "r1",
+		"DB_TABLE":          "users",
+		"DB_QUERY":          "SELECT *\nFROM users",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("%s = %q, want %q", name, fields[name], value)
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journal_handler_test.go") {
+		t.Errorf("CODE_FILE = %q", fields["CODE_FILE"])
+	}
+	if fields["CODE_LINE"] == "" || fields["CODE_FUNC"] == "" {
+		t.Errorf("missing CODE_LINE or CODE_FUNC: %v", fields)
+	}
+}
+
+func TestJournalHandler_PrefixesReservedFields(t *testing.T) {
+	socket, next := listenUnixgram(t)
+	h, err := logutil.NewJournalHandler(&logutil.JournalHandlerArgs{
+		Socket:           socket,
+		SyslogIdentifier: "myapp",
+	})
+	if err != nil {
+		t.Fatalf("NewJournalHandler: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+
+	slog.New(h).With("priority", "high").Warn("disk full",
+		"message", "from attr",
+		"syslog_identifier", "other",
+		"code_line", 1,
+	)
+	msg := next()
+	fields := parseJournalFields(t, msg)
+
+	want := map[string]string{
+		"MESSAGE":                "disk full",
+		"PRIORITY":               "4",
+		"SYSLOG_IDENTIFIER":      "myapp",
+		"USER_MESSAGE":           "from attr",
+		"USER_PRIORITY":          "high",
+		"USER_SYSLOG_IDENTIFIER": "other",
+		"USER_CODE_LINE":         "1",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("%s = %q, want %q", name, fields[name], value)
+		}
+	}
+	if n := strings.Count(msg, "\nMESSAGE="); n != 0 || !strings.HasPrefix(msg, "MESSAGE=") {
+		t.Errorf("expected MESSAGE exactly once, got:\n%s", msg)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":               "USER_ID",
+		"req.path":              "REQ_PATH",
+		"_SYSTEMD_UNIT":         "SYSTEMD_UNIT",
+		"2fa":                   "FA",
+		"___":                   "",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+	for key, want := range tests {
+		if got := logutil.JournalFieldName(key); got != want {
+			t.Errorf("JournalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestNewJournalHandler_MissingSocket(t *testing.T) {
+	_, err := logutil.NewJournalHandler(&logutil.JournalHandlerArgs{
+		Socket: dt.Filepath(filepath.Join(t.TempDir(), "missing.sock")),
+	})
+	if !errors.Is(err, logutil.ErrJournalUnavailable) {
+		t.Fatalf("expected ErrJournalUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input string
+		want  slog.Level
+	}{
+		{input: "debug", want: slog.LevelDebug},
+		{input: "INFO", want: slog.LevelInfo},
+		{input: "warn", want: slog.LevelWarn},
+		{input: "Warning", want: slog.LevelWarn},
+		{input: " error ", want: slog.LevelError},
+		{input: "info+2", want: slog.LevelInfo + 2},
+		{input: "warning-1", want: slog.LevelWarn - 1},
+	}
+	for _, tt := range tests {
+		got, err := logutil.ParseLevel(tt.input)
+		if err != nil {
+			t.Errorf("ParseLevel(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+	_, err := logutil.ParseLevel("loud")
+	if !errors.Is(err, logutil.ErrInvalidLevel) {
+		t.Errorf("expected ErrInvalidLevel, got %v", err)
+	}
+}
+
+func TestSetLevel_SharedByConstructors(t *testing.T) {
+	prev := logutil.GetLevel()
+	t.Cleanup(func() { logutil.SetLevel(prev) })
+
+	logger := logutil.CreateStderrTextLogger()
+	logutil.SetLevel(slog.LevelDebug)
+	if !logger.Enabled(t.Context(), slog.LevelDebug) {
+		t.Errorf("expected debug to be enabled after SetLevel(debug)")
+	}
+	logutil.SetLevel(slog.LevelWarn)
+	if logger.Enabled(t.Context(), slog.LevelInfo) {
+		t.Errorf("expected info to be disabled after SetLevel(warn)")
+	}
+}
+
+func TestSetLevelFor_Reverts(t *testing.T) {
+	prev := logutil.GetLevel()
+	t.Cleanup(func() { logutil.SetLevel(prev) })
+
+	logutil.SetLevel(slog.LevelInfo)
+	logutil.SetLevelFor(slog.LevelDebug, 10*time.Millisecond)
+	if logutil.GetLevel() != slog.LevelDebug {
+		t.Fatalf("expected level to be debug, got %v", logutil.GetLevel())
+	}
+	deadline := time.Now().Add(time.Second)
+	for logutil.GetLevel() != slog.LevelInfo && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if logutil.GetLevel() != slog.LevelInfo {
+		t.Errorf("expected level to revert to info, got %v", logutil.GetLevel())
+	}
+
+	revert := logutil.SetLevelFor(slog.LevelDebug, time.Hour)
+	logutil.SetLevel(slog.LevelError)
+	revert()
+	if logutil.GetLevel() != slog.LevelError {
+		t.Errorf("expected revert to keep level set in between, got %v", logutil.GetLevel())
+	}
+}
+
+func TestSetLevelFor_KeepsSameLevelSetInBetween(t *testing.T) {
+	prev := logutil.GetLevel()
+	t.Cleanup(func() { logutil.SetLevel(prev) })
+
+	logutil.SetLevel(slog.LevelInfo)
+	revert := logutil.SetLevelFor(slog.LevelDebug, time.Hour)
+	logutil.SetLevel(slog.LevelDebug)
+	revert()
+	if logutil.GetLevel() != slog.LevelDebug {
+		t.Errorf("expected revert to keep debug set again in between, got %v", logutil.GetLevel())
+	}
+
+	revert = logutil.SetLevelFor(slog.LevelWarn, time.Hour)
+	inner := logutil.SetLevelFor(slog.LevelError, time.Hour)
+	revert()
+	if logutil.GetLevel() != slog.LevelError {
+		t.Errorf("expected outer revert to keep the inner level, got %v", logutil.GetLevel())
+	}
+	inner()
+	if logutil.GetLevel() != slog.LevelWarn {
+		t.Errorf("expected inner revert to restore warn, got %v", logutil.GetLevel())
+	}
+}
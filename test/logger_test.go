@@ -0,0 +1,84 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestCreateLogger_JSONWithTrimmedSource(t *testing.T) {
+	var buf bytes.Buffer
+	var m struct {
+		Source struct {
+			File string `json:"file"`
+		} `json:"source"`
+		Time *string `json:"time"`
+	}
+
+	logger := logutil.CreateLogger(&logutil.LoggerArgs{
+		Writer:    &buf,
+		Format:    logutil.JSONFormat,
+		Level:     slog.LevelDebug,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger.Debug("hello")
+
+	err := json.Unmarshal(buf.Bytes(), &m)
+	if err != nil {
+		t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+	}
+	if m.Source.File != "logger_test.go" {
+		t.Errorf("expected source file relative to working dir, got %q", m.Source.File)
+	}
+	if m.Time != nil {
+		t.Errorf("expected ReplaceAttr to drop time, got %q", *m.Time)
+	}
+}
+
+func TestCreateLogger_Formats(t *testing.T) {
+	tests := []struct {
+		format logutil.LogFormat
+		want   string
+	}{
+		{format: logutil.TextFormat, want: "level=INFO msg=hello k=v"},
+		{format: logutil.JSONFormat, want: `"msg":"hello","k":"v"`},
+		{format: logutil.ConsoleFormat, want: "INF hello"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+
+		logger := logutil.CreateLogger(&logutil.LoggerArgs{
+			Writer: &buf,
+			Format: tt.format,
+			Color:  logutil.NeverColor,
+		})
+		logger.Info("hello", "k", "v")
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("format %d: expected %q in %q", tt.format, tt.want, buf.String())
+		}
+	}
+}
+
+func TestCreateLogger_ConsoleSource(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logutil.CreateLogger(&logutil.LoggerArgs{
+		Writer:    &buf,
+		Format:    logutil.ConsoleFormat,
+		AddSource: true,
+	})
+	logger.Info("hello")
+	if !strings.Contains(buf.String(), " source=logger_test.go:") {
+		t.Errorf("expected trimmed source location, got %q", buf.String())
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+func TestRecorder_RequireLogged(t *testing.T) {
+	rec := logutiltest.NewRecorder(t)
+	logger := rec.Logger().WithGroup("req").With("id", 7)
+	logger.Warn("slow request", "path", "/a", "err", errors.New("timeout"))
+
+	got := rec.RequireLogged(t, slog.LevelWarn, "slow request",
+		"req.id", 7,
+		slog.String("req.path", "/a"),
+		logutiltest.AttrContains("req.err", "time"),
+		logutiltest.HasAttr("req.path"),
+	)
+	if got.Message != "slow request" {
+		t.Errorf("expected returned record, got %+v", got)
+	}
+	if _, ok := rec.Find(slog.LevelWarn, "slow request", "req.id", 8); ok {
+		t.Errorf("expected mismatched attribute not to match")
+	}
+	rec.RequireNotLogged(t, slog.LevelError, "slow request")
+}
+
+func TestRecorder_SetLevelAppliesToDerivedHandlers(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	logger := rec.Logger().WithGroup("req").With("id", 7)
+	rec.SetLevel(slog.LevelWarn)
+	logger.Info("below level")
+	logger.Warn("at level")
+
+	rec.RequireNotLogged(t, slog.LevelInfo, "below level")
+	rec.RequireLogged(t, slog.LevelWarn, "at level", "req.id", 7)
+}
+
+// completingTB records what is logged to it and runs its cleanups on complete().
+type completingTB struct {
+	testing.TB
+	cleanups []func()
+	logged   []string
+}
+
+func (tb *completingTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *completingTB) Log(args ...any) {
+	tb.logged = append(tb.logged, fmt.Sprint(args...))
+}
+
+func (tb *completingTB) complete() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func TestRecorder_StopsRoutingAfterTestCompletes(t *testing.T) {
+	tb := &completingTB{TB: t}
+	rec := logutiltest.NewRecorder(tb)
+	rec.Logger().Info("during")
+	tb.complete()
+	rec.Logger().Info("late")
+
+	if len(tb.logged) != 1 || !strings.Contains(tb.logged[0], "during") {
+		t.Errorf("expected only the record logged during the test to be routed, got %q", tb.logged)
+	}
+	rec.RequireLogged(t, slog.LevelInfo, "late")
+}
+
+func TestInstall_CapturesPackageLogger(t *testing.T) {
+	logutiltest.Install(t)
+	logutil.Logger().Info("via logutil", "n", 1)
+	slog.Debug("via default")
+
+	logutiltest.RequireLogged(t, slog.LevelInfo, "via logutil", "n", 1)
+	logutiltest.RequireLogged(t, slog.LevelDebug, "via default")
+}
+
+func TestInstall_RestoresUnsetPackageLogger(t *testing.T) {
+	prev := logutil.SwapLogger(nil)
+	t.Cleanup(func() { logutil.SwapLogger(prev) })
+
+	t.Run("installed", func(t *testing.T) {
+		logutiltest.Install(t)
+	})
+	if l := logutil.SwapLogger(nil); l != nil {
+		t.Errorf("expected the package logger to be unset again, got %v", l)
+	}
+}
+
+func TestRecorder_RequireGoldenJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.golden")
+
+	rec := logutiltest.NewRecorder(nil)
+	rec.Logger().WithGroup("g").Info("hello", "k", "v")
+
+	prev := logutiltest.UpdateGolden
+	t.Cleanup(func() { logutiltest.UpdateGolden = prev })
+	logutiltest.UpdateGolden = true
+	rec.RequireGoldenJSON(t, path)
+	logutiltest.UpdateGolden = false
+	rec.RequireGoldenJSON(t, path)
+
+	want := `{"level":"INFO","msg":"hello","g":{"k":"v"}}` + "\n"
+	if string(rec.JSON()) != want {
+		t.Errorf("unexpected JSON\n got: %s\nwant: %s", rec.JSON(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+type failingHandler struct {
+	slog.Handler
+	err error
+}
+
+func (h failingHandler) Handle(context.Context, slog.Record) error {
+	return h.err
+}
+
+func TestMultiHandler_PerSinkLevels(t *testing.T) {
+	var text, json bytes.Buffer
+
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: slog.NewTextHandler(&text, nil), Level: slog.LevelWarn},
+		logutil.Sink{Handler: slog.NewJSONHandler(&json, nil), Level: slog.LevelDebug},
+	))
+	logger = logger.WithGroup("g").With("k", "v")
+	logger.Debug("debug message")
+	logger.Warn("warn message")
+
+	if strings.Contains(text.String(), "debug message") {
+		t.Errorf("text sink should not receive debug records, got %s", text.String())
+	}
+	if !strings.Contains(text.String(), "warn message") || !strings.Contains(text.String(), "g.k=v") {
+		t.Errorf("text sink should receive warn record with group attrs, got %s", text.String())
+	}
+	if strings.Count(json.String(), "\n") != 2 {
+		t.Errorf("json sink should receive both records, got %s", json.String())
+	}
+	if !strings.Contains(json.String(), `"g":{"k":"v"}`) {
+		t.Errorf("json sink should receive group attrs, got %s", json.String())
+	}
+}
+
+func TestMultiHandler_JoinsErrors(t *testing.T) {
+	err1 := errors.New("sink 1 failed")
+	err2 := errors.New("sink 2 failed")
+	base := slog.NewTextHandler(&bytes.Buffer{}, nil)
+
+	h := logutil.NewMultiHandler(
+		logutil.Sink{Handler: failingHandler{Handler: base, err: err1}},
+		logutil.Sink{Handler: base},
+		logutil.Sink{Handler: failingHandler{Handler: base, err: err2}},
+	)
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0))
+	if !errors.Is(err, err1) || !errors.Is(err, err2) {
+		t.Errorf("expected both sink errors, got %v", err)
+	}
+}
+
+func TestMultiHandler_GetJSONFilepath(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "test.log"))
+
+	fileLogger, err := logutil.CreateJSONFileLogger(file)
+	if err != nil {
+		t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+	}
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: slog.NewTextHandler(&bytes.Buffer{}, nil)},
+		logutil.Sink{Handler: fileLogger.Handler()},
+	)).With("k", "v")
+
+	got := logutil.GetJSONFilepath(logger)
+	if got != file {
+		t.Errorf("expected filepath %q, got %q", file, got)
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func TestLevelRegistry_Level(t *testing.T) {
+	r := logutil.NewLevelRegistry()
+	err := r.Parse("db=debug, http.*=warn, *=error, http.client=info")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	tests := []struct {
+		name   string
+		want   slog.Level
+		wantOK bool
+	}{
+		{name: "db", want: slog.LevelDebug, wantOK: true},
+		{name: "http.server", want: slog.LevelWarn, wantOK: true},
+		{name: "http.client", want: slog.LevelInfo, wantOK: true},
+		{name: "cache", want: slog.LevelError, wantOK: true},
+		{name: "a/b"},
+	}
+	for _, tt := range tests {
+		got, ok := r.Level(tt.name)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("Level(%q) = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLevelRegistry_ParseErrors(t *testing.T) {
+	r := logutil.NewLevelRegistry()
+	for _, spec := range []string{"db", "=debug", "db=loud", "[=info"} {
+		err := r.Parse(spec)
+		if !errors.Is(err, logutil.ErrInvalidLevelSpec) {
+			t.Errorf("Parse(%q): expected ErrInvalidLevelSpec, got %v", spec, err)
+		}
+	}
+}
+
+func TestNamedLogger_RuntimeLevelChange(t *testing.T) {
+	var buf bytes.Buffer
+
+	base := slog.New(slog.NewTextHandler(&buf, nil))
+	db := logutil.NamedLogger(base, "test.db")
+	t.Cleanup(func() { _ = logutil.SetNamedLevels("") })
+
+	db.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("expected debug to be disabled by default, got %s", buf.String())
+	}
+
+	err := logutil.SetNamedLevels("test.*=debug")
+	if err != nil {
+		t.Fatalf("SetNamedLevels() failed: %v", err)
+	}
+	db.Debug("shown")
+	if !strings.Contains(buf.String(), "msg=shown logger=test.db") {
+		t.Errorf("expected named debug record, got %s", buf.String())
+	}
+
+	err = logutil.SetNamedLevel("test.db", slog.LevelError)
+	if err != nil {
+		t.Fatalf("SetNamedLevel() failed: %v", err)
+	}
+	buf.Reset()
+	db.Warn("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected warn to be disabled, got %s", buf.String())
+	}
+}
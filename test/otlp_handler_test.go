@@ -0,0 +1,346 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-dt/appinfo"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// collector is an httptest server standing in for an OTLP or log aggregation
+// endpoint. It replies with the queued status codes, then 200.
+type collector struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newCollector(t *testing.T, statuses ...int) *collector {
+	c := &collector{statuses: statuses}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.requests = append(c.requests, r)
+		c.bodies = append(c.bodies, body)
+		status := http.StatusOK
+		if len(c.statuses) > 0 {
+			status, c.statuses = c.statuses[0], c.statuses[1:]
+		}
+		c.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *collector) received() (reqs []*http.Request, bodies [][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.bodies
+}
+
+func testAppInfo() appinfo.AppInfo {
+	return appinfo.New(appinfo.Args{Name: "myapp", Version: "1.2.3"})
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			LogRecords []struct {
+				SeverityNumber int            `json:"severityNumber"`
+				SeverityText   string         `json:"severityText"`
+				Body           map[string]any `json:"body"`
+				Attributes     []otlpKeyValue `json:"attributes"`
+				TraceID        string         `json:"traceId"`
+				SpanID         string         `json:"spanId"`
+				Flags          int            `json:"flags"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func TestOTLPHandler_JSON(t *testing.T) {
+	c := newCollector(t)
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint:      c.URL,
+		Encoding:      logutil.OTLPJSON,
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		AppInfo:       testAppInfo(),
+		ResourceAttrs: []slog.Attr{slog.String("deployment.environment", "test")},
+		Level:         slog.LevelDebug,
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h).WithGroup("http")
+
+	ctx, err := logutil.ContextWithTraceparent(context.Background(),
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("ContextWithTraceparent() failed: %v", err)
+	}
+	logger.WarnContext(ctx, "slow request", "path", "/api", "ms", 1500)
+	err = h.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	reqs, bodies := c.received()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	if ct := reqs[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if auth := reqs[0].Header.Get("Authorization"); auth != "Bearer token" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	var req otlpRequest
+	err = json.Unmarshal(bodies[0], &req)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v\n%s", err, bodies[0])
+	}
+	rl := req.ResourceLogs[0]
+	resource := make(map[string]any)
+	for _, kv := range rl.Resource.Attributes {
+		resource[kv.Key] = kv.Value["stringValue"]
+	}
+	if resource["service.name"] != "myapp" || resource["service.version"] != "1.2.3" ||
+		resource["deployment.environment"] != "test" {
+		t.Errorf("unexpected resource attributes: %v", resource)
+	}
+	lr := rl.ScopeLogs[0].LogRecords[0]
+	if lr.SeverityNumber != 13 || lr.Body["stringValue"] != "slow request" {
+		t.Errorf("unexpected record: %+v", lr)
+	}
+	if lr.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || lr.SpanID != "00f067aa0ba902b7" || lr.Flags != 1 {
+		t.Errorf("unexpected trace context: %+v", lr)
+	}
+	if len(lr.Attributes) != 1 || lr.Attributes[0].Key != "http" {
+		t.Fatalf("expected http group attribute, got %+v", lr.Attributes)
+	}
+	group, _ := json.Marshal(lr.Attributes[0].Value)
+	want := `{"kvlistValue":{"values":[{"key":"path","value":{"stringValue":"/api"}},{"key":"ms","value":{"intValue":"1500"}}]}}`
+	if string(group) != want {
+		t.Errorf("got %s\nwant %s", group, want)
+	}
+}
+
+// countValuer is a slog.LogValuer whose value changes after it is logged.
+type countValuer struct {
+	n *int
+}
+
+func (v countValuer) LogValue() slog.Value {
+	return slog.IntValue(*v.n)
+}
+
+func TestOTLPHandler_ResolvesAttrsWhenLogged(t *testing.T) {
+	c := newCollector(t)
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint: c.URL,
+		Encoding: logutil.OTLPJSON,
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	n := 1
+	tags := map[string]int{"a": 1}
+	slog.New(h).Warn("logged", slog.Group("g", "count", countValuer{n: &n}, "tags", tags))
+	// Modifying logged values must neither change nor race the export.
+	n = 2
+	tags["b"] = 2
+	err := h.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	_, bodies := c.received()
+	var req otlpRequest
+	err = json.Unmarshal(bodies[0], &req)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	group, _ := json.Marshal(req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes[0].Value)
+	want := `{"kvlistValue":{"values":[{"key":"count","value":{"intValue":"1"}},{"key":"tags","value":{"stringValue":"map[a:1]"}}]}}`
+	if string(group) != want {
+		t.Errorf("got %s\nwant %s", group, want)
+	}
+}
+
+// protoField returns the first length-delimited field numbered field in msg.
+func protoField(t *testing.T, msg []byte, field int) []byte {
+	t.Helper()
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(msg)
+			msg = msg[n:]
+		case 1:
+			msg = msg[8:]
+		case 5:
+			msg = msg[4:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			value := msg[n : n+int(size)]
+			msg = msg[n+int(size):]
+			if int(tag>>3) == field {
+				return value
+			}
+		default:
+			t.Fatalf("unexpected wire type in tag %d", tag)
+		}
+	}
+	t.Fatalf("field %d not found", field)
+	return nil
+}
+
+func TestOTLPHandler_Protobuf(t *testing.T) {
+	c := newCollector(t)
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint: c.URL,
+		AppInfo:  testAppInfo(),
+		Gzip:     true,
+	})
+	slog.New(h).Error("boom")
+	err := h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	reqs, bodies := c.received()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	if ct := reqs[0].Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if ce := reqs[0].Header.Get("Content-Encoding"); ce != "gzip" {
+		t.Errorf("Content-Encoding = %q", ce)
+	}
+	body := gunzip(t, bodies[0])
+
+	resourceLogs := protoField(t, body, 1)
+	serviceName := protoField(t, protoField(t, protoField(t, resourceLogs, 1), 1), 1)
+	if string(serviceName) != "service.name" {
+		t.Errorf("first resource attribute = %q", serviceName)
+	}
+	logRecord := protoField(t, protoField(t, resourceLogs, 2), 2)
+	if got := string(protoField(t, logRecord, 3)); got != "ERROR" {
+		t.Errorf("severity text = %q", got)
+	}
+	if got := string(protoField(t, protoField(t, logRecord, 5), 1)); got != "boom" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestOTLPHandler_RetriesWithBackoff(t *testing.T) {
+	c := newCollector(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint: c.URL,
+		Backoff:  logutil.BackoffArgs{Initial: time.Millisecond, Max: 5 * time.Millisecond},
+	})
+	slog.New(h).Info("eventually")
+	err := h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if reqs, _ := c.received(); len(reqs) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(reqs))
+	}
+}
+
+func TestOTLPHandler_FallbackFile(t *testing.T) {
+	var reported []error
+
+	c := newCollector(t, http.StatusBadRequest)
+	fallback := dt.Filepath(filepath.Join(t.TempDir(), "spill", "otlp.jsonl"))
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint:     c.URL,
+		Encoding:     logutil.OTLPJSON,
+		FallbackFile: fallback,
+		OnError:      func(err error) { reported = append(reported, err) },
+	})
+	slog.New(h).Info("rejected")
+	err := h.Flush(context.Background())
+	if !errors.Is(err, logutil.ErrExportFailed) {
+		t.Fatalf("expected ErrExportFailed, got %v", err)
+	}
+	if reqs, _ := c.received(); len(reqs) != 1 {
+		t.Errorf("expected no retry of a 400, got %d requests", len(reqs))
+	}
+	if len(reported) != 1 {
+		t.Errorf("expected 1 reported error, got %v", reported)
+	}
+	data, err := fallback.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var req otlpRequest
+	err = json.Unmarshal([]byte(lines[0]), &req)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if body := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body["stringValue"]; body != "rejected" {
+		t.Errorf("fallback body = %v", body)
+	}
+	_ = h.Close()
+}
+
+func TestOTLPHandler_ReportsRecordsAfterClose(t *testing.T) {
+	var reported []error
+
+	c := newCollector(t)
+	h := logutil.NewOTLPHandler(&logutil.OTLPHandlerArgs{
+		Endpoint: c.URL,
+		OnError:  func(err error) { reported = append(reported, err) },
+	})
+	err := h.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	slog.New(h).Info("too late")
+	if len(reported) != 1 || !errors.Is(reported[0], logutil.ErrExporterClosed) {
+		t.Errorf("expected ErrExporterClosed reported, got %v", reported)
+	}
+	if reqs, _ := c.received(); len(reqs) != 0 {
+		t.Errorf("expected no requests, got %d", len(reqs))
+	}
+}
+
+func gunzip(t *testing.T, b []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() failed: %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("io.ReadAll() failed: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+const testPackage = "github.com/mikeschinkel/go-logutil/test"
+
+var pkgLogger = logutil.PackageLogger()
+
+func TestPackageLogger_PopulatedByInitializers(t *testing.T) {
+	var buf bytes.Buffer
+
+	prev := logutil.SwapLogger(nil)
+	t.Cleanup(func() { logutil.SwapLogger(prev) })
+
+	child := pkgLogger.WithGroup("g").With("k", "v")
+	root := slog.New(slog.NewTextHandler(&buf, nil))
+	err := logutil.CallInitializerFuncs(logutil.InitializerArgs{Logger: root})
+	if err != nil {
+		t.Fatalf("CallInitializerFuncs() failed: %v", err)
+	}
+
+	pkgLogger.Info("hello")
+	child.Info("child")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "msg=hello pkg="+testPackage) {
+		t.Errorf("expected pkg attribute, got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "msg=child pkg="+testPackage+" g.k=v") {
+		t.Errorf("expected pkg and group attributes, got %q", lines[1])
+	}
+	if logutil.Logger() != root {
+		t.Errorf("expected package logger to be the unscoped root logger")
+	}
+}
+
+func TestInitializerArgs_ScopedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	var pkg string
+
+	r := logutil.NewInitializerRegistry()
+	r.Register("scoped", func(args logutil.InitializerArgs) error {
+		pkg = args.Package
+		args.Logger.Info("init")
+		return nil
+	})
+	err := r.Call(logutil.InitializerArgs{Logger: slog.New(slog.NewTextHandler(&buf, nil))})
+	if err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if pkg != testPackage {
+		t.Errorf("expected package %q, got %q", testPackage, pkg)
+	}
+	if !strings.Contains(buf.String(), "pkg="+testPackage) {
+		t.Errorf("expected scoped logger, got %q", buf.String())
+	}
+}
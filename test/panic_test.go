@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+func TestRecoverAndLog_LogsPanicWithBufferedRecords(t *testing.T) {
+	sink := logutiltest.NewRecorder(nil)
+	sink.SetLevel(slog.LevelInfo)
+	ring := logutil.NewRingBufferHandler(&logutil.RingBufferHandlerArgs{DumpHandler: sink})
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: sink},
+		logutil.Sink{Handler: ring},
+	))
+
+	func() {
+		defer logutil.RecoverAndLog(&logutil.RecoverArgs{Logger: logger})
+		logger.Debug("about to fail", "step", 3)
+		panic("kaboom")
+	}()
+
+	sink.RequireLogged(t, slog.LevelDebug, "about to fail", "step", 3, logutil.ReplayedKey, true)
+	sink.RequireLogged(t, slog.LevelError, logutil.PanicMessage,
+		"panic", "kaboom",
+		logutiltest.AttrContains("stack", "panic_test.go"),
+	)
+}
+
+func TestRecoverAndLog_RePanics(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+
+	defer func() {
+		r := recover()
+		if r != "again" {
+			t.Errorf("expected re-panic with original value, got %v", r)
+		}
+		rec.RequireLogged(t, slog.LevelError, "custom", "panic", "again")
+	}()
+	defer logutil.RecoverAndLog(&logutil.RecoverArgs{
+		Logger:  rec.Logger(),
+		Message: "custom",
+		RePanic: true,
+	})
+	panic("again")
+}
+
+func TestRecoverAndLog_BoundsFlushingByFlushTimeout(t *testing.T) {
+	c := newCollector(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	h, err := logutil.NewHTTPBatchHandler(&logutil.HTTPBatchHandlerArgs{
+		URL:     c.URL,
+		Backoff: logutil.BackoffArgs{MaxAttempts: 3, Initial: time.Minute, Max: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPBatchHandler() failed: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_ = h.Shutdown(ctx)
+	})
+
+	start := time.Now()
+	func() {
+		defer logutil.RecoverAndLog(&logutil.RecoverArgs{
+			Logger:       slog.New(h),
+			FlushTimeout: 50 * time.Millisecond,
+		})
+		panic("collector down")
+	}()
+	elapsed := time.Since(start)
+	if elapsed > 5*time.Second {
+		t.Fatalf("RecoverAndLog() took %v despite a FlushTimeout of 50ms", elapsed)
+	}
+}
+
+func TestRecoverAndLog_LoggingContinuesAfterRecovery(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "app.log"))
+	logger, err := logutil.CreateJSONFileLogger(file)
+	if err != nil {
+		t.Fatalf("CreateJSONFileLogger() failed: %v", err)
+	}
+
+	func() {
+		defer logutil.RecoverAndLog(&logutil.RecoverArgs{Logger: logger})
+		panic("recoverable")
+	}()
+	logger.Error("after recovery")
+
+	data, err := file.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"msg":"after recovery"`) {
+		t.Fatalf("record logged after recovery is missing from:\n%s", data)
+	}
+}
+
+func TestRecoverAndLog_LeavesRingWithoutDumpHandler(t *testing.T) {
+	sink := logutiltest.NewRecorder(nil)
+	ring := logutil.NewRingBufferHandler(nil)
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: sink, Level: slog.LevelInfo},
+		logutil.Sink{Handler: ring},
+	))
+
+	func() {
+		defer logutil.RecoverAndLog(&logutil.RecoverArgs{Logger: logger})
+		logger.Debug("about to fail")
+		panic("kaboom")
+	}()
+
+	sink.RequireNotLogged(t, slog.LevelDebug, "about to fail")
+	var got []string
+	for _, r := range ring.Records() {
+		got = append(got, r.Message)
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == logutil.ReplayedKey {
+				t.Errorf("expected %q not to be replayed into the ring", r.Message)
+			}
+			return true
+		})
+	}
+	if strings.Join(got, ",") != "about to fail,"+logutil.PanicMessage {
+		t.Errorf("expected the ring to keep its records once each, got %v", got)
+	}
+}
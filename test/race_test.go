@@ -0,0 +1,74 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// These tests are meant to be run with -race.
+
+func TestSetLogger_ConcurrentWithLogger(t *testing.T) {
+	var wg sync.WaitGroup
+
+	loggers := []*slog.Logger{
+		slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+	}
+	prev := logutil.SwapLogger(loggers[0])
+	t.Cleanup(func() { logutil.SwapLogger(prev) })
+
+	for n := range 8 {
+		wg.Go(func() {
+			for range 100 {
+				if n%2 == 0 {
+					logutil.SetLogger(loggers[n%4/2])
+					continue
+				}
+				if logutil.Logger() == nil {
+					t.Error("expected Logger() to return a logger")
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestInitializerRegistry_ConcurrentRegistration(t *testing.T) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var calls int
+
+	r := logutil.NewInitializerRegistry()
+	for range 8 {
+		wg.Go(func() {
+			for range 50 {
+				r.RegisterFunc(func(logutil.InitializerArgs) error {
+					mu.Lock()
+					calls++
+					mu.Unlock()
+					return nil
+				})
+				r.RegisterFinalizerFunc(func(context.Context) error { return nil })
+				_, _ = r.Initializers()
+			}
+		})
+	}
+	wg.Wait()
+
+	err := r.Call(logutil.InitializerArgs{})
+	if err != nil {
+		t.Fatalf("Call() failed: %v", err)
+	}
+	if calls != 400 {
+		t.Errorf("expected 400 initializer calls, got %d", calls)
+	}
+	err = r.CallFinalizers(context.Background())
+	if err != nil {
+		t.Errorf("CallFinalizers() failed: %v", err)
+	}
+}
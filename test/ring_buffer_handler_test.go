@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+func TestRingBufferHandler_KeepsLastN(t *testing.T) {
+	ring := logutil.NewRingBufferHandler(&logutil.RingBufferHandlerArgs{Size: 3})
+	logger := slog.New(ring).WithGroup("g").With("k", "v")
+
+	for _, msg := range []string{"1", "2", "3", "4", "5"} {
+		logger.Debug(msg)
+	}
+	records := ring.Records()
+	var got []string
+	for _, r := range records {
+		got = append(got, r.Message)
+	}
+	if strings.Join(got, ",") != "3,4,5" {
+		t.Errorf("expected the last 3 records, got %v", got)
+	}
+
+	rec := logutiltest.NewRecorder(nil)
+	err := ring.DumpTo(context.Background(), rec)
+	if err != nil {
+		t.Fatalf("DumpTo() failed: %v", err)
+	}
+	rec.RequireLogged(t, slog.LevelDebug, "5", "g.k", "v", logutil.ReplayedKey, true)
+	if len(ring.Records()) != 0 {
+		t.Errorf("expected the buffer to be empty after dumping")
+	}
+}
+
+func TestRingBufferHandler_DumpsOnError(t *testing.T) {
+	sink := logutiltest.NewRecorder(nil)
+	sink.SetLevel(slog.LevelInfo)
+	ring := logutil.NewRingBufferHandler(&logutil.RingBufferHandlerArgs{
+		DumpLevel:   slog.LevelError,
+		DumpHandler: sink,
+	})
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: sink},
+		logutil.Sink{Handler: ring},
+	))
+
+	logger.Debug("debug context")
+	logger.Info("info")
+	sink.RequireNotLogged(t, slog.LevelDebug, "debug context")
+
+	logger.Error("failure")
+	sink.RequireLogged(t, slog.LevelDebug, "debug context", logutil.ReplayedKey, true)
+	sink.RequireLogged(t, slog.LevelError, "failure")
+}
+
+func TestRingBufferHandler_DumpToFile(t *testing.T) {
+	file := dt.Filepath(filepath.Join(t.TempDir(), "crash.log"))
+
+	ring := logutil.NewRingBufferHandler(nil)
+	slog.New(ring).Debug("before crash", "n", 1)
+
+	err := ring.DumpToFile(context.Background(), file)
+	if err != nil {
+		t.Fatalf("DumpToFile() failed: %v", err)
+	}
+	out, err := os.ReadFile(string(file))
+	if err != nil {
+		t.Fatalf("failed to read dump: %v", err)
+	}
+	if !strings.Contains(string(out), `"level":"DEBUG","msg":"before crash","n":1,"replayed":true`) {
+		t.Errorf("unexpected dump contents: %s", out)
+	}
+}
+
+func TestRingBufferHandler_DumpToLoggerContainingRing(t *testing.T) {
+	sink := logutiltest.NewRecorder(nil)
+	ring := logutil.NewRingBufferHandler(nil)
+	logger := slog.New(logutil.NewMultiHandler(
+		logutil.Sink{Handler: sink},
+		logutil.Sink{Handler: ring},
+	))
+	logger.Warn("buffered")
+
+	err := ring.DumpToLogger(context.Background(), logger)
+	if err != nil {
+		t.Fatalf("DumpToLogger() failed: %v", err)
+	}
+	sink.RequireLogged(t, slog.LevelWarn, "buffered", logutil.ReplayedKey, true)
+	if n := len(ring.Records()); n != 0 {
+		t.Errorf("expected dumped records not to be buffered again, got %d", n)
+	}
+}
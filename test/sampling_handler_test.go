@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-logutil"
+	"github.com/mikeschinkel/go-logutil/logutiltest"
+)
+
+// fakeClock is a manually advanced clock for time-based handlers.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSamplingHandler_FirstThenEveryMth(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rec := logutiltest.NewRecorder(nil)
+	h := logutil.NewSamplingHandler(rec, &logutil.SamplingHandlerArgs{
+		Interval: time.Second,
+		Rule:     logutil.SamplingRule{First: 2, Thereafter: 3},
+		Now:      clock.Now,
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h)
+
+	for range 10 {
+		logger.Info("hot loop")
+		logger.Error("failure")
+	}
+	// Passes records 1, 2, 5 and 8 of the hot loop message.
+	if got := countMessages(rec, "hot loop"); got != 4 {
+		t.Errorf("expected 4 sampled records, got %d", got)
+	}
+	if got := countMessages(rec, "failure"); got != 10 {
+		t.Errorf("expected errors never to be sampled, got %d", got)
+	}
+	if got := countMessages(rec, logutil.SamplingSummaryMessage); got != 0 {
+		t.Errorf("expected no summary before the interval elapsed, got %d", got)
+	}
+
+	clock.Advance(time.Second)
+	logger.Info("hot loop")
+	rec.RequireLogged(t, slog.LevelInfo, logutil.SamplingSummaryMessage,
+		"sampled_msg", "hot loop",
+		"suppressed", 6,
+		"total", 10,
+	)
+	if got := countMessages(rec, "hot loop"); got != 5 {
+		t.Errorf("expected counts to reset after the interval, got %d", got)
+	}
+}
+
+func TestSamplingHandler_LevelRulesAndFlush(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	h := logutil.NewSamplingHandler(rec, &logutil.SamplingHandlerArgs{
+		Rule: logutil.SamplingRule{First: 1},
+		LevelRules: map[slog.Level]logutil.SamplingRule{
+			slog.LevelWarn: {},
+		},
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h).With("k", "v")
+
+	for range 5 {
+		logger.Debug("debug")
+		logger.Warn("warn")
+	}
+	if got := countMessages(rec, "debug"); got != 1 {
+		t.Errorf("expected 1 debug record, got %d", got)
+	}
+	if got := countMessages(rec, "warn"); got != 5 {
+		t.Errorf("expected warnings to be unsampled, got %d", got)
+	}
+
+	err := h.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	rec.RequireLogged(t, slog.LevelDebug, logutil.SamplingSummaryMessage, "k", "v", "suppressed", 4)
+}
+
+func TestSamplingHandler_SummarizesWithoutFurtherRecords(t *testing.T) {
+	rec := logutiltest.NewRecorder(nil)
+	h := logutil.NewSamplingHandler(rec, &logutil.SamplingHandlerArgs{
+		Interval: 20 * time.Millisecond,
+		Rule:     logutil.SamplingRule{First: 1},
+	})
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h).WithGroup("req").With("id", 7)
+	for range 3 {
+		logger.Info("hot loop")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for countMessages(rec, logutil.SamplingSummaryMessage) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("summary was not emitted after the interval elapsed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	rec.RequireLogged(t, slog.LevelInfo, logutil.SamplingSummaryMessage,
+		"req.id", 7,
+		"req.sampled_msg", "hot loop",
+		"req.suppressed", 2,
+		"req.total", 3,
+	)
+}
+
+func countMessages(rec *logutiltest.Recorder, msg string) (n int) {
+	for _, r := range rec.Records() {
+		if r.Message == msg {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,228 @@
+package test
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+func spoolMessages(t *testing.T, records []logutil.SpoolRecord) (msgs []string) {
+	t.Helper()
+	for _, rec := range records {
+		msgs = append(msgs, ndjsonMessages(t, rec.Data)...)
+	}
+	return msgs
+}
+
+func segmentFiles(t *testing.T, dir dt.DirPath) (names []string) {
+	t.Helper()
+	entries, err := dir.ReadDir()
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".jsonl") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestSpool_ResumesFromCheckpointAndDeletesAcked(t *testing.T) {
+	dir := dt.DirPath(filepath.Join(t.TempDir(), "spool"))
+	args := &logutil.SpoolArgs{MaxSegmentBytes: 150, Level: slog.LevelDebug}
+	w, err := logutil.OpenSpoolWriter(dir, args)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	logger := slog.New(logutil.NewSpoolHandler(w, args))
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		logger.Info(msg)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if n := len(segmentFiles(t, dir)); n < 3 {
+		t.Fatalf("expected rotation into several segments, got %d", n)
+	}
+
+	r, err := logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	recs, err := r.Read(3)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := strings.Join(spoolMessages(t, recs), ","); got != "one,two,three" {
+		t.Fatalf("expected one,two,three, got %q", got)
+	}
+	err = r.Ack(recs[len(recs)-1].Next)
+	if err != nil {
+		t.Fatalf("Ack() failed: %v", err)
+	}
+
+	// A new reader, e.g. after a restart, resumes after the acknowledged records.
+	r, err = logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	recs, err = r.Read(10)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := strings.Join(spoolMessages(t, recs), ","); got != "four,five" {
+		t.Fatalf("expected four,five after resuming, got %q", got)
+	}
+	err = r.Ack(recs[len(recs)-1].Next)
+	if err != nil {
+		t.Fatalf("Ack() failed: %v", err)
+	}
+	if files := segmentFiles(t, dir); len(files) != 0 {
+		t.Errorf("acknowledged segments should be deleted, found %v", files)
+	}
+	if recs, _ = r.Read(10); len(recs) != 0 {
+		t.Errorf("expected nothing more to read, got %d records", len(recs))
+	}
+}
+
+func TestSpoolReader_Rewind(t *testing.T) {
+	dir := dt.DirPath(t.TempDir())
+	w, err := logutil.OpenSpoolWriter(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	slog.New(logutil.NewSpoolHandler(w, nil)).Warn("retry me")
+
+	r, err := logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	if recs, _ := r.Read(10); len(recs) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(recs))
+	}
+	if recs, _ := r.Read(10); len(recs) != 0 {
+		t.Fatalf("expected no records before Rewind, got %d", len(recs))
+	}
+	r.Rewind()
+	if recs, _ := r.Read(10); len(recs) != 1 {
+		t.Fatalf("expected the record again after Rewind, got %d", len(recs))
+	}
+}
+
+func TestSpool_RecoversFromCrash(t *testing.T) {
+	dir := dt.DirPath(t.TempDir())
+	w, err := logutil.OpenSpoolWriter(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	slog.New(logutil.NewSpoolHandler(w, nil)).Warn("before crash")
+	// Simulate a crash mid-write: a partial record and no Close().
+	_, err = w.Write([]byte(`{"msg":"torn`))
+	if err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	r, err := logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	recs, err := r.Read(10)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := spoolMessages(t, recs); len(got) != 1 || got[0] != "before crash" {
+		t.Fatalf("partial record in the open segment should be left, got %v", got)
+	}
+
+	// The next writer seals the abandoned segment and starts a new one.
+	w2, err := logutil.OpenSpoolWriter(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	slog.New(logutil.NewSpoolHandler(w2, nil)).Warn("after restart")
+	err = w2.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	recs, err = r.Read(10)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := spoolMessages(t, recs); len(got) != 1 || got[0] != "after restart" {
+		t.Fatalf("torn record should be skipped once sealed, got %v", got)
+	}
+}
+
+func TestOpenSpoolReader_InvalidCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, logutil.SpoolCheckpointFile), []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("os.WriteFile() failed: %v", err)
+	}
+	_, err = logutil.OpenSpoolReader(dt.DirPath(dir))
+	if !errors.Is(err, logutil.ErrInvalidSpoolCheckpoint) {
+		t.Fatalf("expected ErrInvalidSpoolCheckpoint, got %v", err)
+	}
+}
+
+func TestSpool_RestartAfterEverythingAcked(t *testing.T) {
+	dir := dt.DirPath(t.TempDir())
+	w, err := logutil.OpenSpoolWriter(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	slog.New(logutil.NewSpoolHandler(w, nil)).Warn("a record long enough to ack past the next one")
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	r, err := logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	recs, err := r.Read(10)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	err = r.Ack(recs[len(recs)-1].Next)
+	if err != nil {
+		t.Fatalf("Ack() failed: %v", err)
+	}
+	if files := segmentFiles(t, dir); len(files) != 0 {
+		t.Fatalf("acknowledged segments should be deleted, found %v", files)
+	}
+
+	// A restarted writer must not reuse the acknowledged segment's number, or a
+	// restarted reader would resume mid-segment.
+	w, err = logutil.OpenSpoolWriter(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenSpoolWriter() failed: %v", err)
+	}
+	logger := slog.New(logutil.NewSpoolHandler(w, nil))
+	logger.Warn("A")
+	logger.Warn("B")
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	r, err = logutil.OpenSpoolReader(dir)
+	if err != nil {
+		t.Fatalf("OpenSpoolReader() failed: %v", err)
+	}
+	recs, err = r.Read(10)
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got := strings.Join(spoolMessages(t, recs), ","); got != "A,B" {
+		t.Fatalf("expected A,B after restart, got %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeschinkel/go-dt"
+	"github.com/mikeschinkel/go-logutil"
+)
+
+// listenUnixgram starts a Unix datagram listener standing in for the syslog or
+// journal daemon and returns its socket path and a func receiving the next
+// message.
+func listenUnixgram(t *testing.T) (dt.Filepath, func() string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return dt.Filepath(path), func() string {
+		t.Helper()
+		buf := make([]byte, 8192)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestSyslogHandler_RFC5424(t *testing.T) {
+	socket, next := listenUnixgram(t)
+	h, err := logutil.NewSyslogHandler(&logutil.SyslogHandlerArgs{
+		Socket:   socket,
+		Facility: logutil.FacilityLocal0,
+		AppName:  "myapp",
+		Hostname: "host1",
+		Level:    slog.LevelDebug,
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogHandler: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	logger := slog.New(h).With("svc", "api").WithGroup("req")
+
+	logger.Warn("slow request", "path", `/a"b]`, "ms", 1500)
+	got := next()
+
+	// local0 (16) * 8 + warning (4)
+	if !strings.HasPrefix(got, "<132>1 ") {
+		t.Errorf("unexpected header: %q", got)
+	}
+	if !strings.Contains(got, " host1 myapp ") {
+		t.Errorf("missing hostname and app name: %q", got)
+	}
+	want := `[attrs@32473 svc="api" req.path="/a\"b\]" req.ms="1500"] slow request`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("got %q\nwant suffix %q", got, want)
+	}
+
+	logger.Debug("no attrs at top level")
+	if got = next(); !strings.HasPrefix(got, "<135>1 ") {
+		t.Errorf("expected debug severity: %q", got)
+	}
+}
+
+func TestSyslogHandler_RFC3164(t *testing.T) {
+	socket, next := listenUnixgram(t)
+	h, err := logutil.NewSyslogHandler(&logutil.SyslogHandlerArgs{
+		Socket:   socket,
+		Format:   logutil.RFC3164,
+		AppName:  "myapp",
+		Hostname: "host1",
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogHandler: %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+
+	slog.New(h).Error("disk full", "mount", "/var", "detail", "no space left")
+	got := next()
+
+	// user (1) * 8 + error (3)
+	if !strings.HasPrefix(got, "<11>") {
+		t.Errorf("unexpected priority: %q", got)
+	}
+	if !strings.Contains(got, " host1 myapp[") {
+		t.Errorf("missing hostname and tag: %q", got)
+	}
+	want := `]: disk full mount=/var detail="no space left"`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("got %q\nwant suffix %q", got, want)
+	}
+}
+
+func TestSyslogSeverityFor(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  logutil.SyslogSeverity
+	}{
+		{slog.LevelDebug - 4, logutil.SeverityDebug},
+		{slog.LevelDebug, logutil.SeverityDebug},
+		{slog.LevelInfo, logutil.SeverityInfo},
+		{slog.LevelWarn, logutil.SeverityWarning},
+		{slog.LevelError, logutil.SeverityError},
+		{logutil.LevelFatal, logutil.SeverityCritical},
+	}
+	for _, tt := range tests {
+		if got := logutil.SyslogSeverityFor(tt.level); got != tt.want {
+			t.Errorf("SyslogSeverityFor(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestNewSyslogHandler_MissingSocket(t *testing.T) {
+	_, err := logutil.NewSyslogHandler(&logutil.SyslogHandlerArgs{
+		Socket: dt.Filepath(filepath.Join(t.TempDir(), "missing.sock")),
+	})
+	if !errors.Is(err, logutil.ErrSyslogUnavailable) {
+		t.Fatalf("expected ErrSyslogUnavailable, got %v", err)
+	}
+}
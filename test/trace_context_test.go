@@ -0,0 +1,105 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/mikeschinkel/go-logutil"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "valid", input: testTraceparent},
+		{name: "future version with extra field", input: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "too few fields", input: "00-4bf92f3577b34da6a3ce929d0e0e4736-01", wantErr: true},
+		{name: "invalid version", input: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "extra field for version 00", input: testTraceparent + "-extra", wantErr: true},
+		{name: "uppercase trace-id", input: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero trace-id", input: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero parent-id", input: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, err := logutil.ParseTraceparent(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, logutil.ErrInvalidTraceparent) {
+					t.Fatalf("expected ErrInvalidTraceparent, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("unexpected trace ID %q", tc.TraceID)
+			}
+			if tc.SpanID != "00f067aa0ba902b7" {
+				t.Errorf("unexpected span ID %q", tc.SpanID)
+			}
+			if !tc.Sampled() {
+				t.Errorf("expected sampled flag to be set")
+			}
+		})
+	}
+}
+
+func TestContextHandler_TraceIDsAreTopLevel(t *testing.T) {
+	var buf bytes.Buffer
+	var m map[string]any
+
+	logger := logutil.WithContext(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logger = logger.WithGroup("req").With("method", "GET")
+
+	ctx, err := logutil.ContextWithTraceparent(context.Background(), testTraceparent)
+	if err != nil {
+		t.Fatalf("ContextWithTraceparent() failed: %v", err)
+	}
+	ctx = logutil.ContextWithArgs(ctx, "tenant", "acme")
+	logger.InfoContext(ctx, "hello", "path", "/")
+
+	err = json.Unmarshal(buf.Bytes(), &m)
+	if err != nil {
+		t.Fatalf("failed to unmarshal log output %q: %v", buf.String(), err)
+	}
+	if m[logutil.TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected top-level trace_id, got %s", buf.String())
+	}
+	if m[logutil.SpanIDKey] != "00f067aa0ba902b7" {
+		t.Errorf("expected top-level span_id, got %s", buf.String())
+	}
+	if m["tenant"] != "acme" {
+		t.Errorf("expected top-level tenant, got %s", buf.String())
+	}
+	req, ok := m["req"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected req group, got %s", buf.String())
+	}
+	if req["method"] != "GET" || req["path"] != "/" {
+		t.Errorf("expected method and path in req group, got %s", buf.String())
+	}
+}
+
+func TestContextHandler_CustomTraceExtractor(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := logutil.NewContextHandler(slog.NewJSONHandler(&buf, nil), &logutil.ContextHandlerArgs{
+		TraceExtractor: func(ctx context.Context) (logutil.TraceContext, bool) {
+			return logutil.TraceContext{TraceID: "abc", SpanID: "def"}, true
+		},
+	})
+	slog.New(h).InfoContext(context.Background(), "hello")
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"trace_id":"abc","span_id":"def"`)) {
+		t.Errorf("expected custom trace IDs, got %s", buf.String())
+	}
+}
@@ -0,0 +1,140 @@
+package logutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceContext identifies the trace and span a log record was produced within.
+// TraceID and SpanID are lowercase hex as used by W3C Trace Context.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   byte
+}
+
+// TraceExtractor extracts the trace context from a context.Context, returning
+// ok==false when ctx carries none. Users of a tracing library can supply their
+// own to ContextHandlerArgs.
+type TraceExtractor func(ctx context.Context) (tc TraceContext, ok bool)
+
+// Attrs returns the trace_id and span_id attributes for tc, omitting empty ones.
+func (tc TraceContext) Attrs() (attrs []slog.Attr) {
+	attrs = make([]slog.Attr, 0, 2)
+	if tc.TraceID != "" {
+		attrs = append(attrs, slog.String(TraceIDKey, tc.TraceID))
+	}
+	if tc.SpanID != "" {
+		attrs = append(attrs, slog.String(SpanIDKey, tc.SpanID))
+	}
+	return attrs
+}
+
+// Sampled reports whether the sampled flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 != 0
+}
+
+type traceCtxKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx carrying tc.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, tc)
+}
+
+// ContextWithTraceparent parses a W3C traceparent header value and returns a copy
+// of ctx carrying the resulting TraceContext.
+func ContextWithTraceparent(ctx context.Context, traceparent string) (_ context.Context, err error) {
+	var tc TraceContext
+
+	tc, err = ParseTraceparent(traceparent)
+	if err != nil {
+		goto end
+	}
+	ctx = ContextWithTraceContext(ctx, tc)
+end:
+	return ctx, err
+}
+
+// TraceContextFromContext returns the TraceContext stored in ctx by
+// ContextWithTraceContext() or ContextWithTraceparent(). It is the default
+// TraceExtractor.
+func TraceContextFromContext(ctx context.Context) (tc TraceContext, ok bool) {
+	if ctx == nil {
+		goto end
+	}
+	tc, ok = ctx.Value(traceCtxKey{}).(TraceContext)
+end:
+	return tc, ok
+}
+
+// ParseTraceparent parses a W3C Trace Context traceparent header value of the
+// form "00-<32 hex trace-id>-<16 hex parent-id>-<2 hex flags>".
+func ParseTraceparent(s string) (tc TraceContext, err error) {
+	var parts []string
+	var flags []byte
+
+	s = strings.TrimSpace(s)
+	parts = strings.Split(s, "-")
+	if len(parts) < 4 {
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "too few fields", "traceparent", s)
+		goto end
+	}
+	switch {
+	case !isLowerHex(parts[0], 2) || parts[0] == "ff":
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "invalid version", "traceparent", s)
+	case parts[0] == "00" && len(parts) != 4:
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "too many fields", "traceparent", s)
+	case !isLowerHex(parts[1], 32) || isAllZeros(parts[1]):
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "invalid trace-id", "traceparent", s)
+	case !isLowerHex(parts[2], 16) || isAllZeros(parts[2]):
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "invalid parent-id", "traceparent", s)
+	case !isLowerHex(parts[3], 2):
+		err = dt.NewErr(ErrInvalidTraceparent, "reason", "invalid trace-flags", "traceparent", s)
+	}
+	if err != nil {
+		goto end
+	}
+	flags, _ = hex.DecodeString(parts[3])
+	tc = TraceContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Flags:   flags[0],
+	}
+end:
+	return tc, err
+}
+
+// Traceparent formats tc as a version 00 W3C traceparent header value.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,67 @@
+package logutil
+
+import (
+	"net"
+	"sync"
+
+	"github.com/mikeschinkel/go-dt"
+)
+
+// unixgramConn is a Unix datagram socket shared by a handler and the handlers
+// derived from it by WithAttrs() and WithGroup().
+type unixgramConn struct {
+	mu     sync.Mutex
+	socket dt.Filepath
+	// unavailable is the error wrapped when the socket cannot be written to.
+	unavailable error
+	conn        net.Conn
+	closed      bool
+}
+
+func (c *unixgramConn) dial() (err error) {
+	c.conn, err = net.Dial("unixgram", string(c.socket))
+	if err != nil {
+		err = dt.NewErr(c.unavailable, "socket", c.socket, err)
+	}
+	return err
+}
+
+// write sends msg, redialing and retrying once should the write fail.
+func (c *unixgramConn) write(msg []byte) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		err = dt.NewErr(c.unavailable, "socket", c.socket, net.ErrClosed)
+		goto end
+	}
+	if c.conn != nil {
+		_, err = c.conn.Write(msg)
+		if err == nil {
+			goto end
+		}
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	err = c.dial()
+	if err != nil {
+		goto end
+	}
+	_, err = c.conn.Write(msg)
+end:
+	return err
+}
+
+func (c *unixgramConn) close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		goto end
+	}
+	c.closed = true
+	if c.conn != nil {
+		err = c.conn.Close()
+		c.conn = nil
+	}
+end:
+	return err
+}